@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/vaidik-bajpai/ecommerce-api/internal/data"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/validator"
+)
+
+func (app *application) listAddressesHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	addresses, err := app.models.Addresses.GetAllForUser(int(user.ID))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"addresses": addresses}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createAddressHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		House     string `json:"house"`
+		Street    string `json:"street"`
+		City      string `json:"city"`
+		Pincode   string `json:"pincode"`
+		IsDefault bool   `json:"is_default"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	address := &data.Address{
+		House:     &input.House,
+		Street:    &input.Street,
+		City:      &input.City,
+		Pincode:   &input.Pincode,
+		IsDefault: input.IsDefault,
+	}
+
+	v := validator.NewValidator()
+
+	if data.ValidateAddress(v, address); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	err = app.models.Addresses.AddAddress(int(user.ID), address)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"address": address}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showAddressHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	address, err := app.models.Addresses.GetForUser(int(id), int(user.ID))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"address": address}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateAddressHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	address, err := app.models.Addresses.GetForUser(int(id), int(user.ID))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		House     *string `json:"house"`
+		Street    *string `json:"street"`
+		City      *string `json:"city"`
+		Pincode   *string `json:"pincode"`
+		IsDefault *bool   `json:"is_default"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.House != nil {
+		address.House = input.House
+	}
+	if input.Street != nil {
+		address.Street = input.Street
+	}
+	if input.City != nil {
+		address.City = input.City
+	}
+	if input.Pincode != nil {
+		address.Pincode = input.Pincode
+	}
+
+	v := validator.NewValidator()
+
+	// The default address can only change by making another address the
+	// default, so the user always has one to check out with.
+	if input.IsDefault != nil {
+		v.Check(*input.IsDefault || !address.IsDefault, "is_default", "cannot be unset, make another address the default instead")
+		address.IsDefault = *input.IsDefault
+	}
+
+	if data.ValidateAddress(v, address); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Addresses.UpdateAddress(address)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"address": address}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteAddressHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	err = app.models.Addresses.RemoveAddress(int(id), int(user.ID))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "address successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
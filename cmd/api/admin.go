@@ -11,10 +11,10 @@ import (
 func (app *application) createProductHandler(w http.ResponseWriter, r *http.Request) {
 
 	var input struct {
-		Name   string `json:"name"`
-		Price  uint64 `json:"price"`
-		Rating uint8  `json:"rating"`
-		Image  string `json:"image"`
+		Name        string  `json:"name"`
+		Description string  `json:"description"`
+		Image       *string `json:"image"`
+		CategoryID  *int64  `json:"category_id"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -24,15 +24,23 @@ func (app *application) createProductHandler(w http.ResponseWriter, r *http.Requ
 	}
 
 	product := &data.Product{
-		Name:   input.Name,
-		Price:  input.Price,
-		Rating: input.Rating,
-		Image:  &input.Image,
+		Name:        input.Name,
+		Description: input.Description,
+		Image:       input.Image,
+		CategoryID:  input.CategoryID,
 	}
 
 	v := validator.NewValidator()
 
-	if data.ValidateProduct(v, product); !v.Valid() {
+	data.ValidateProduct(v, product)
+
+	err = app.checkCategoryExists(v, "category_id", product.CategoryID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
@@ -50,6 +58,91 @@ func (app *application) createProductHandler(w http.ResponseWriter, r *http.Requ
 
 }
 
+func (app *application) updateProductHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	product, err := app.models.Products.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !app.expectedVersionMatches(r, product.Version) {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+		Image       *string `json:"image"`
+		CategoryID  *int64  `json:"category_id"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		product.Name = *input.Name
+	}
+	if input.Description != nil {
+		product.Description = *input.Description
+	}
+	if input.Image != nil {
+		product.Image = input.Image
+	}
+	// A category_id of 0 takes the product out of its category.
+	if input.CategoryID != nil {
+		product.CategoryID = input.CategoryID
+		if *input.CategoryID == 0 {
+			product.CategoryID = nil
+		}
+	}
+
+	v := validator.NewValidator()
+
+	data.ValidateProduct(v, product)
+
+	err = app.checkCategoryExists(v, "category_id", product.CategoryID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Products.Update(product)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"product": product}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
 func (app *application) deleteProductHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil {
@@ -73,3 +166,187 @@ func (app *application) deleteProductHandler(w http.ResponseWriter, r *http.Requ
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+func (app *application) updateOrderStatusHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.NewValidator()
+
+	if data.ValidateOrderStatus(v, input.Status); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	order, err := app.models.Orders.Transition(id, input.Status, &user.ID, input.Note)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		case errors.Is(err, data.ErrInvalidTransition):
+			v.AddErrors("status", "the order cannot move to this status from its current status")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.background(func() {
+		app.settlePayment(order)
+	})
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"order": order}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateUserPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Permissions data.Permissions `json:"permissions"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Permissions == nil {
+		input.Permissions = data.Permissions{}
+	}
+
+	v := validator.NewValidator()
+
+	if data.ValidatePermissions(v, input.Permissions); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Users.SetPermissions(id, input.Permissions)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"permissions": input.Permissions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) adjustStockHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Delta  int    `json:"delta"`
+		Reason string `json:"reason"`
+		Note   string `json:"note"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.NewValidator()
+
+	if data.ValidateStockAdjustment(v, input.Delta, input.Reason, input.Note); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	variant, err := app.models.Variants.AdjustStock(id, input.Delta, input.Reason, input.Note, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		case errors.Is(err, data.ErrInsufficientStock):
+			v.AddErrors("delta", "would leave less stock on hand than is reserved")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"variant": variant}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listStockMovementsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	var input struct {
+		data.Filters
+	}
+
+	qs := r.URL.Query()
+
+	v := validator.NewValidator()
+
+	input.Page = app.readInt(qs, "page", 1, v)
+	input.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Sort = app.readString(qs, "sort", "-id", v)
+	input.Filters.SortSafeList = []string{"id", "-id"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movements, metadata, err := app.models.Variants.GetStockMovements(id, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"stock_movements": movements, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
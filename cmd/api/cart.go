@@ -1,48 +1,388 @@
 package main
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/vaidik-bajpai/ecommerce-api/internal/data"
 	"github.com/vaidik-bajpai/ecommerce-api/internal/validator"
 )
 
+func (app *application) showCartHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	cart, err := app.models.Carts.Get(int(user.ID))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"cart": cart}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
 func (app *application) addToCartHandler(w http.ResponseWriter, r *http.Request) {
-	qs := r.URL.Query()
+	var input struct {
+		VariantID int `json:"variant_id"`
+		Quantity  int `json:"quantity"`
+	}
+
+	input.Quantity = 1
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
 	v := validator.NewValidator()
 
-	userId := app.readQueryInt(qs, "user_id", v)
-	productId := app.readQueryInt(qs, "product_id", v)
+	v.Check(input.VariantID > 0, "variant_id", "must be a positive integer value")
+	data.ValidateCartQuantity(v, input.Quantity)
 
-	if userId == -1 || productId == -1 || !v.Valid() {
+	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	if !v.Valid() {
+	user := app.contextGetUser(r)
+
+	err = app.models.Carts.AddToCart(int(user.ID), input.VariantID, input.Quantity)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddErrors("variant_id", "must refer to an existing product variant")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrCartQuantityLimit):
+			v.AddErrors("quantity", "would take the cart over 100 of this item")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "The product is added to cart"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) setCartItemQuantityHandler(w http.ResponseWriter, r *http.Request) {
+	variantID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Quantity int `json:"quantity"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.NewValidator()
+
+	if data.ValidateCartQuantity(v, input.Quantity); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err := app.models.Carts.AddToCart(userId, productId)
+	user := app.contextGetUser(r)
+
+	err = app.models.Carts.SetItemQuantity(int(user.ID), int(variantID), input.Quantity)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "The quantity is updated"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) incrementCartItemHandler(w http.ResponseWriter, r *http.Request) {
+	variantID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"message": "The product is added to cart"}, nil)
+	v := validator.NewValidator()
+
+	quantity := app.readInt(r.URL.Query(), "quantity", 1, v)
+
+	if data.ValidateCartQuantity(v, quantity); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	err = app.models.Carts.IncrementItem(int(user.ID), int(variantID), quantity)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		case errors.Is(err, data.ErrCartQuantityLimit):
+			v.AddErrors("quantity", "would take the cart over 100 of this item")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "The quantity is updated"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) decrementCartItemHandler(w http.ResponseWriter, r *http.Request) {
+	variantID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	v := validator.NewValidator()
+
+	quantity := app.readInt(r.URL.Query(), "quantity", 1, v)
+
+	if data.ValidateCartQuantity(v, quantity); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	err = app.models.Carts.DecrementItem(int(user.ID), int(variantID), quantity)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "The quantity is updated"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
 func (app *application) removeItemHandler(w http.ResponseWriter, r *http.Request) {
-	w.Write([]byte("removeItemHandler endpoint"))
+	variantID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	qs := r.URL.Query()
+	v := validator.NewValidator()
+
+	quantity := app.readInt(qs, "quantity", 0, v)
+	if qs.Has("quantity") {
+		data.ValidateCartQuantity(v, quantity)
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	if quantity > 0 {
+		err = app.models.Carts.DecrementItem(int(user.ID), int(variantID), quantity)
+	} else {
+		err = app.models.Carts.RemoveItem(int(user.ID), int(variantID))
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	cart, err := app.models.Carts.Get(int(user.ID))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"cart": cart}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
 }
 
 func (app *application) cartCheckoutHandler(w http.ResponseWriter, r *http.Request) {
-	w.Write([]byte("cartCheckoutHandler endpoint"))
+	var input struct {
+		AddressID int64 `json:"address_id"`
+		PaymentID int64 `json:"payment_id"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.NewValidator()
+
+	v.Check(input.AddressID >= 0, "address_id", "must not be negative")
+	v.Check(input.PaymentID > 0, "payment_id", "must be a positive integer value")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	order, err := app.models.Carts.Checkout(user.ID, input.AddressID, input.PaymentID)
+	if err != nil {
+		if message, ok := couponErrorMessage(err); ok {
+			v.AddErrors("coupon", message)
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		switch {
+		case errors.Is(err, data.ErrEmptyCart):
+			v.AddErrors("cart", "must contain atleast one product")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrInvalidAddress):
+			v.AddErrors("address_id", "must refer to one of your addresses")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrNoDefaultAddress):
+			v.AddErrors("address_id", "must be provided when you have no default address")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrInvalidPayment):
+			v.AddErrors("payment_id", "must refer to a valid payment method")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrInsufficientStock):
+			v.AddErrors("quantity", "there is not enough stock to fulfil this order")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	paid, err := app.authorizeOrder(order)
+	if err != nil {
+		// The order is marked as failed by now, so its items and coupon go
+		// back in the cart for the shopper to try again.
+		restoreErr := app.models.Carts.RestoreOrder(order.ID, user.ID)
+		if restoreErr != nil && !errors.Is(restoreErr, data.ErrRecordNotFound) {
+			app.logError(r, restoreErr)
+		}
+
+		switch {
+		case errors.Is(err, errPaymentDeclined) && errors.Is(restoreErr, data.ErrCartQuantityLimit):
+			app.errorResponse(w, r, http.StatusPaymentRequired, "your payment was declined and the items could not be put back in your cart, as it would hold more than 100 of an item")
+		case errors.Is(err, errPaymentDeclined):
+			app.paymentDeclinedResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"order": paid}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
 }
 
 func (app *application) instantBuyHandler(w http.ResponseWriter, r *http.Request) {
-	w.Write([]byte("instantBuyHandler endpoint"))
+	var input struct {
+		VariantID int64 `json:"variant_id"`
+		Quantity  int   `json:"quantity"`
+		AddressID int64 `json:"address_id"`
+		PaymentID int64 `json:"payment_id"`
+	}
+
+	input.Quantity = 1
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.NewValidator()
+
+	v.Check(input.VariantID > 0, "variant_id", "must be a positive integer value")
+	data.ValidateCartQuantity(v, input.Quantity)
+	v.Check(input.AddressID >= 0, "address_id", "must not be negative")
+	v.Check(input.PaymentID > 0, "payment_id", "must be a positive integer value")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	order, err := app.models.Orders.InstantBuy(user.ID, input.VariantID, input.Quantity, input.AddressID, input.PaymentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddErrors("variant_id", "must refer to an existing product variant")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrInvalidAddress):
+			v.AddErrors("address_id", "must refer to one of your addresses")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrNoDefaultAddress):
+			v.AddErrors("address_id", "must be provided when you have no default address")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrInvalidPayment):
+			v.AddErrors("payment_id", "must refer to a valid payment method")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrInsufficientStock):
+			v.AddErrors("quantity", "there is not enough stock to fulfil this order")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	order, err = app.authorizeOrder(order)
+	if err != nil {
+		switch {
+		case errors.Is(err, errPaymentDeclined):
+			app.paymentDeclinedResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"order": order}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
 }
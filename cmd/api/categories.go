@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/vaidik-bajpai/ecommerce-api/internal/data"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/validator"
+)
+
+// checkCategoryExists adds a validation error under key if the category with
+// the given id does not exist.
+func (app *application) checkCategoryExists(v *validator.Validator, key string, categoryID *int64) error {
+	if categoryID == nil {
+		return nil
+	}
+
+	_, err := app.models.Categories.Get(*categoryID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddErrors(key, "category does not exist")
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lookupCategory finds a category by its id or, failing that, by its slug.
+func (app *application) lookupCategory(idOrSlug string) (*data.Category, error) {
+	id, err := strconv.ParseInt(idOrSlug, 10, 64)
+	if err == nil && id > 0 {
+		return app.models.Categories.Get(id)
+	}
+
+	return app.models.Categories.GetBySlug(idOrSlug)
+}
+
+func (app *application) listCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	categories, err := app.models.Categories.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"categories": data.BuildCategoryTree(categories)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	category, err := app.models.Categories.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"category": category}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name     string `json:"name"`
+		Slug     string `json:"slug"`
+		ParentID *int64 `json:"parent_id"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	category := &data.Category{
+		Name:     input.Name,
+		Slug:     input.Slug,
+		ParentID: input.ParentID,
+	}
+
+	v := validator.NewValidator()
+
+	data.ValidateCategory(v, category)
+
+	err = app.checkCategoryExists(v, "parent_id", category.ParentID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Categories.Insert(category)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateSlug):
+			v.AddErrors("slug", "a category with this slug already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"category": category}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	category, err := app.models.Categories.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Name     *string `json:"name"`
+		Slug     *string `json:"slug"`
+		ParentID *int64  `json:"parent_id"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		category.Name = *input.Name
+	}
+	if input.Slug != nil {
+		category.Slug = *input.Slug
+	}
+	// A parent_id of 0 moves the category to the top of the tree.
+	if input.ParentID != nil {
+		category.ParentID = input.ParentID
+		if *input.ParentID == 0 {
+			category.ParentID = nil
+		}
+	}
+
+	v := validator.NewValidator()
+
+	data.ValidateCategory(v, category)
+
+	err = app.checkCategoryExists(v, "parent_id", category.ParentID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Categories.Update(category)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateSlug):
+			v.AddErrors("slug", "a category with this slug already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrCategoryCycle):
+			v.AddErrors("parent_id", "must not be one of the category's own subcategories")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"category": category}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	err = app.models.Categories.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		case errors.Is(err, data.ErrCategoryHasChildren):
+			app.errorResponse(w, r, http.StatusConflict, "category still has subcategories")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "category successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/vaidik-bajpai/ecommerce-api/internal/data"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/validator"
+)
+
+// couponErrorMessage explains why a coupon cannot be used, if err says so.
+func couponErrorMessage(err error) (string, bool) {
+	switch {
+	case errors.Is(err, data.ErrCouponExpired):
+		return "this coupon has expired", true
+	case errors.Is(err, data.ErrCouponUsedUp):
+		return "this coupon has reached its usage limit", true
+	case errors.Is(err, data.ErrCouponMinOrder):
+		return "your order does not reach this coupon's minimum value", true
+	case errors.Is(err, data.ErrCouponNotEligible):
+		return "this coupon does not apply to any item in your cart", true
+	default:
+		return "", false
+	}
+}
+
+func (app *application) applyCouponHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code string `json:"code"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	input.Code = data.NormalizeCouponCode(input.Code)
+
+	v := validator.NewValidator()
+
+	if data.ValidateCouponCode(v, input.Code); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	cart, err := app.models.Carts.ApplyCoupon(int(user.ID), input.Code)
+	if err != nil {
+		if message, ok := couponErrorMessage(err); ok {
+			v.AddErrors("code", message)
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddErrors("code", "must be a valid coupon code")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEmptyCart):
+			v.AddErrors("cart", "must contain atleast one product")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"cart": cart}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) removeCouponHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	err := app.models.Carts.RemoveCoupon(int(user.ID))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	cart, err := app.models.Carts.Get(int(user.ID))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"cart": cart}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createCouponHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code           string     `json:"code"`
+		Kind           string     `json:"kind"`
+		Value          int        `json:"value"`
+		MinOrder       int        `json:"min_order"`
+		ExpiresAt      *time.Time `json:"expires_at"`
+		MaxUses        *int       `json:"max_uses"`
+		MaxUsesPerUser *int       `json:"max_uses_per_user"`
+		ProductIDs     []int      `json:"product_ids"`
+		CategoryIDs    []int      `json:"category_ids"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	coupon := &data.Coupon{
+		Code:           data.NormalizeCouponCode(input.Code),
+		Kind:           input.Kind,
+		Value:          input.Value,
+		MinOrder:       input.MinOrder,
+		ExpiresAt:      input.ExpiresAt,
+		MaxUses:        input.MaxUses,
+		MaxUsesPerUser: input.MaxUsesPerUser,
+		ProductIDs:     input.ProductIDs,
+		CategoryIDs:    input.CategoryIDs,
+	}
+
+	if coupon.ProductIDs == nil {
+		coupon.ProductIDs = []int{}
+	}
+	if coupon.CategoryIDs == nil {
+		coupon.CategoryIDs = []int{}
+	}
+
+	v := validator.NewValidator()
+
+	if data.ValidateCoupon(v, coupon); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Coupons.Insert(coupon)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateCouponCode):
+			v.AddErrors("code", "a coupon with this code already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"coupon": coupon}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -60,3 +60,28 @@ func (app *application) authenticationRequiredResponse(w http.ResponseWriter, r
 	message := "you must be authenticated to access this resource"
 	app.errorResponse(w, r, http.StatusUnauthorized, message)
 }
+
+func (app *application) invalidSignatureResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid or missing signature"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
+	message := "unable to update the record due to an edit conflict, please try again"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account doesn't have the necessary permissions to access this resource"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) inactiveAccountResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account must be activated to access this resource"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) paymentDeclinedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your payment was declined, please try again with another payment method"
+	app.errorResponse(w, r, http.StatusPaymentRequired, message)
+}
@@ -91,6 +91,13 @@ func (app *application) readIDParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
+// expectedVersionMatches reports whether the X-Expected-Version header, if the
+// client sent one, names the version of the record it is about to update.
+func (app *application) expectedVersionMatches(r *http.Request, version int) bool {
+	expected := r.Header.Get("X-Expected-Version")
+	return expected == "" || expected == strconv.Itoa(version)
+}
+
 func (app *application) readInt(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
 	strValue := qs.Get(key)
 
@@ -132,3 +139,19 @@ func (app *application) readString(qs url.Values, key string, defaultValue strin
 
 	return strValue
 }
+
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Println(fmt.Errorf("%s", err))
+			}
+		}()
+
+		fn()
+	}()
+}
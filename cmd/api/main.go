@@ -4,9 +4,8 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
-	"time"
+	"sync"
 
 	"github.com/vaidik-bajpai/ecommerce-api/internal/prisma/db"
 
@@ -14,6 +13,8 @@ import (
 	_ "github.com/lib/pq"
 
 	"github.com/vaidik-bajpai/ecommerce-api/internal/data"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/mailer"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/payments"
 )
 
 type config struct {
@@ -25,12 +26,24 @@ type config struct {
 	jwt struct {
 		secret string
 	}
+	mailer struct {
+		backend string
+		dir     string
+		sender  string
+	}
+	payments struct {
+		gateway       string
+		webhookSecret string
+	}
 }
 
 type application struct {
-	config config
-	logger *log.Logger
-	models data.Models
+	config   config
+	logger   *log.Logger
+	models   data.Models
+	mailer   mailer.Mailer
+	payments payments.Gateway
+	wg       sync.WaitGroup
 }
 
 func main() {
@@ -40,6 +53,7 @@ func main() {
 	}
 
 	var cfg config
+	var grantAdmin string
 
 	flag.StringVar(&cfg.env, "env", "development", "Environment (development|staging|production)")
 	flag.IntVar(&cfg.port, "port", 4000, "http network address")
@@ -47,6 +61,15 @@ func main() {
 	flag.StringVar(&cfg.db.dsn, "db-dsn", os.Getenv("ECOMMERCE_DB_DSN"), "dsn for the database")
 
 	flag.StringVar(&cfg.jwt.secret, "jwt", os.Getenv("JWT_SECRET"), "secret for json web token")
+
+	flag.StringVar(&cfg.mailer.backend, "mailer", "log", "Mailer backend (log|file)")
+	flag.StringVar(&cfg.mailer.dir, "mailer-dir", "./tmp/mail", "directory the file mailer writes emails to")
+	flag.StringVar(&cfg.mailer.sender, "mailer-sender", "Ecommerce <no-reply@ecommerce.local>", "sender of outgoing emails")
+
+	flag.StringVar(&cfg.payments.gateway, "payment-gateway", "mock", "Payment gateway (mock)")
+	flag.StringVar(&cfg.payments.webhookSecret, "payment-webhook-secret", os.Getenv("PAYMENT_WEBHOOK_SECRET"), "secret payment webhooks are signed with")
+
+	flag.StringVar(&grantAdmin, "grant-admin", "", "email of a user to grant every admin permission to, then exit")
 	flag.Parse()
 
 	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime|log.Lshortfile)
@@ -59,20 +82,54 @@ func main() {
 
 	defer db.Prisma.Disconnect()
 
+	gateway, err := cfg.newPaymentGateway()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
+		config:   cfg,
+		logger:   logger,
+		models:   data.NewModels(db),
+		mailer:   cfg.newMailer(logger),
+		payments: gateway,
+	}
+
+	if grantAdmin != "" {
+		err = app.models.Users.GrantAdmin(grantAdmin)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		logger.Printf("granted admin permissions to %s", grantAdmin)
+		return
+	}
+
+	err = app.serve()
+	if err != nil {
+		logger.Fatal(err)
 	}
+}
 
-	srv := http.Server{
-		Addr:        fmt.Sprintf(":%d", cfg.port),
-		Handler:     app.routes(),
-		IdleTimeout: 10 * time.Second,
-		ReadTimeout: 30 * time.Second,
+func (c config) newMailer(logger *log.Logger) mailer.Mailer {
+	switch c.mailer.backend {
+	case "file":
+		return mailer.NewFileMailer(c.mailer.dir, c.mailer.sender)
+	default:
+		return mailer.NewLogMailer(logger, c.mailer.sender)
 	}
+}
 
-	srv.ListenAndServe()
+// newPaymentGateway returns the gateway payments are taken through. The mock
+// gateway is the only one so far; any other name is a configuration mistake,
+// so it stops the server from starting rather than falling back to the mock.
+func (c config) newPaymentGateway() (payments.Gateway, error) {
+	switch c.payments.gateway {
+	case "mock":
+		return payments.NewMockGateway(), nil
+	default:
+		return nil, fmt.Errorf("unknown payment gateway %q", c.payments.gateway)
+	}
 }
 
 func (c config) openDB() (*db.PrismaClient, error) {
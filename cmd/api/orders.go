@@ -1,11 +1,123 @@
 package main
 
-/* func (app *application) placeOrder(w http.ResponseWriter, r *http.Request) {
+import (
+	"errors"
+	"net/http"
+
+	"github.com/vaidik-bajpai/ecommerce-api/internal/data"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/validator"
+)
+
+func (app *application) listOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filters
+	}
+
+	qs := r.URL.Query()
+
+	v := validator.NewValidator()
+
+	input.Page = app.readInt(qs, "page", 1, v)
+	input.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Sort = app.readString(qs, "sort", "-id", v)
+	input.Filters.SortSafeList = []string{"id", "price", "ordered_at", "-id", "-price", "-ordered_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	orders, metadata, err := app.models.Orders.GetAllForUser(user.ID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"orders": orders, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showOrderHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	order, err := app.models.Orders.GetForUser(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"order": order}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) cancelOrderHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason"`
+	}
+
+	if r.ContentLength != 0 {
+		err = app.readJSON(w, r, &input)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
 	v := validator.NewValidator()
-	userId := app.readQueryInt(r.URL.Query(), "user_id", v)
-	if !v.Valid() {
+
+	if v.Check(len(input.Reason) <= 500, "reason", "must not be more than 500 bytes"); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-} */
+	user := app.contextGetUser(r)
+
+	order, err := app.models.Orders.Cancel(id, user.ID, input.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		case errors.Is(err, data.ErrNotCancellable), errors.Is(err, data.ErrInvalidTransition):
+			v.AddErrors("status", "the order can no longer be cancelled")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.background(func() {
+		app.settlePayment(order)
+	})
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"order": order}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
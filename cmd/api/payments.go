@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/vaidik-bajpai/ecommerce-api/internal/data"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/payments"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/validator"
+)
+
+var errPaymentDeclined = errors.New("payment declined")
+
+// callGateway runs one gateway operation for an order and records the attempt,
+// whatever its outcome.
+func (app *application) callGateway(orderID int64, operation string, amount int64, call func(ctx context.Context) (*payments.Result, error)) (*payments.Result, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := call(ctx)
+
+	attempt := &data.PaymentAttempt{
+		OrderID:   orderID,
+		Gateway:   app.payments.Name(),
+		Operation: operation,
+		Amount:    amount,
+	}
+
+	if err != nil {
+		message := err.Error()
+		attempt.Status = data.PaymentAttemptError
+		attempt.Error = &message
+	} else {
+		attempt.Status = result.Status
+		attempt.TransactionID = &result.TransactionID
+		if result.Message != "" {
+			attempt.Error = &result.Message
+		}
+	}
+
+	if recordErr := app.models.PaymentAttempts.Insert(attempt); recordErr != nil {
+		app.logger.Println(recordErr)
+	}
+
+	return result, err
+}
+
+// authorizeOrder authorizes the total of a newly placed order with the
+// payment gateway. An approved order is marked paid and a declined one is
+// marked as failed, which releases its stock. A pending authorization leaves
+// the order waiting for the provider to confirm it.
+func (app *application) authorizeOrder(order *data.Order) (*data.Order, error) {
+	if order.Total == 0 {
+		return app.models.Orders.Transition(order.ID, data.OrderStatusPaid, nil, "nothing to pay")
+	}
+
+	amount := int64(order.Total)
+
+	result, err := app.callGateway(order.ID, data.PaymentOperationAuthorize, amount, func(ctx context.Context) (*payments.Result, error) {
+		return app.payments.Authorize(ctx, payments.AuthorizeRequest{
+			OrderReference: order.Reference,
+			Amount:         amount,
+		})
+	})
+
+	if err != nil {
+		_, failErr := app.models.Orders.Transition(order.ID, data.OrderStatusPaymentFailed, nil, "payment could not be authorized")
+		if failErr != nil {
+			app.logger.Println(failErr)
+		}
+		return nil, err
+	}
+
+	switch result.Status {
+	case payments.StatusApproved:
+		return app.models.Orders.Transition(order.ID, data.OrderStatusPaid, nil, "payment authorized")
+	case payments.StatusDeclined:
+		_, err = app.models.Orders.Transition(order.ID, data.OrderStatusPaymentFailed, nil, result.Message)
+		if err != nil {
+			return nil, err
+		}
+		return nil, errPaymentDeclined
+	default:
+		return order, nil
+	}
+}
+
+// settlePayment moves money to match an order's new status. The
+// authorization is captured when the order ships, and voided or refunded when
+// the order is cancelled or refunded. Failures are recorded and logged rather
+// than undoing the status change, so they can be retried by hand.
+func (app *application) settlePayment(order *data.Order) {
+	attempts, err := app.models.PaymentAttempts.GetAllForOrder(order.ID)
+	if err != nil {
+		app.logger.Println(err)
+		return
+	}
+
+	var authorization *data.PaymentAttempt
+	var captured bool
+
+	for _, attempt := range attempts {
+		if attempt.Status != payments.StatusApproved {
+			continue
+		}
+
+		switch attempt.Operation {
+		case data.PaymentOperationAuthorize:
+			authorization = attempt
+		case data.PaymentOperationCapture:
+			captured = true
+		case data.PaymentOperationVoid, data.PaymentOperationRefund:
+			return
+		}
+	}
+
+	if authorization == nil || authorization.TransactionID == nil {
+		return
+	}
+
+	transactionID := *authorization.TransactionID
+	amount := authorization.Amount
+	ending := validator.In(order.Status, data.OrderStatusCancelled, data.OrderStatusRefunded)
+
+	switch {
+	case order.Status == data.OrderStatusShipped && !captured:
+		_, err = app.callGateway(order.ID, data.PaymentOperationCapture, amount, func(ctx context.Context) (*payments.Result, error) {
+			return app.payments.Capture(ctx, transactionID, amount)
+		})
+	case ending && captured:
+		_, err = app.callGateway(order.ID, data.PaymentOperationRefund, amount, func(ctx context.Context) (*payments.Result, error) {
+			return app.payments.Refund(ctx, transactionID, amount)
+		})
+	case ending:
+		_, err = app.callGateway(order.ID, data.PaymentOperationVoid, amount, func(ctx context.Context) (*payments.Result, error) {
+			return app.payments.Void(ctx, transactionID)
+		})
+	}
+
+	if err != nil {
+		app.logger.Println(err)
+	}
+}
@@ -18,7 +18,13 @@ func (app *application) searchProductByIDHandler(w http.ResponseWriter, r *http.
 
 	product, err := app.models.Products.Get(id)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
 	}
 
 	err = app.writeJSON(w, http.StatusOK, envelope{"product": product}, nil)
@@ -29,8 +35,9 @@ func (app *application) searchProductByIDHandler(w http.ResponseWriter, r *http.
 
 func (app *application) searchProductHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Name  string
-		Price uint
+		Name     string
+		Price    uint
+		Category string
 		data.Filters
 	}
 
@@ -40,26 +47,45 @@ func (app *application) searchProductHandler(w http.ResponseWriter, r *http.Requ
 
 	input.Name = app.readString(qs, "product_name", "", v)
 	input.Price = uint(app.readInt(qs, "price", math.MaxInt32, v))
+	input.Category = app.readString(qs, "category", "", v)
 
 	input.Page = app.readInt(qs, "page", 1, v)
 	input.PageSize = app.readInt(qs, "page_size", 20, v)
 
-	input.Sort = app.readString(qs, "sort", "id", v)
-	input.Filters.SortSafeList = []string{"id", "name", "price", "-id", "rating", "-name", "-price", "-rating"}
+	// Searches are ranked by relevance unless another order is asked for.
+	defaultSort := "id"
+	if input.Name != "" {
+		defaultSort = "relevance"
+	}
+
+	input.Sort = app.readString(qs, "sort", defaultSort, v)
+	input.Filters.SortSafeList = []string{"id", "name", "price", "-id", "rating", "-name", "-price", "-rating", "relevance"}
 
 	if data.ValidateFilters(v, input.Filters); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	products, metadata, err := app.models.Products.GetAll(input.Name, int(input.Price), input.Filters)
-	if err != nil {
-		switch {
-		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundErrorResponse(w, r)
-		default:
-			app.serverErrorResponse(w, r, err)
+	var categoryID int64
+	if input.Category != "" {
+		category, err := app.lookupCategory(input.Category)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				v.AddErrors("category", "must refer to an existing category")
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
 		}
+
+		categoryID = category.ID
+	}
+
+	products, metadata, err := app.models.Products.GetAll(input.Name, int(input.Price), categoryID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
 		return
 	}
 
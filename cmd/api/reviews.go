@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/vaidik-bajpai/ecommerce-api/internal/data"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/validator"
+)
+
+func (app *application) listReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	productID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	var input struct {
+		data.Filters
+	}
+
+	qs := r.URL.Query()
+
+	v := validator.NewValidator()
+
+	input.Page = app.readInt(qs, "page", 1, v)
+	input.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Sort = app.readString(qs, "sort", "-id", v)
+	input.Filters.SortSafeList = []string{"id", "rating", "-id", "-rating"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	reviews, metadata, err := app.models.Reviews.GetAll(productID, data.ReviewStatusApproved, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"reviews": reviews, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createReviewHandler(w http.ResponseWriter, r *http.Request) {
+	productID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Products.Get(productID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Rating int    `json:"rating"`
+		Body   string `json:"body"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	review := &data.Review{
+		ProductID: productID,
+		UserID:    user.ID,
+		Rating:    input.Rating,
+		Body:      input.Body,
+	}
+
+	v := validator.NewValidator()
+
+	if data.ValidateReview(v, review); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Reviews.Insert(review)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNotVerifiedBuyer):
+			app.errorResponse(w, r, http.StatusForbidden, "only customers who have received this product can review it")
+		case errors.Is(err, data.ErrDuplicateReview):
+			app.errorResponse(w, r, http.StatusConflict, "you have already reviewed this product")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"review": review}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listReviewsForModerationHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Status string
+		data.Filters
+	}
+
+	qs := r.URL.Query()
+
+	v := validator.NewValidator()
+
+	input.Status = app.readString(qs, "status", data.ReviewStatusPending, v)
+
+	input.Page = app.readInt(qs, "page", 1, v)
+	input.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Sort = app.readString(qs, "sort", "id", v)
+	input.Filters.SortSafeList = []string{"id", "rating", "-id", "-rating"}
+
+	v.Check(validator.In(input.Status, data.ReviewStatuses...), "status", "invalid status value")
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	reviews, metadata, err := app.models.Reviews.GetAll(0, input.Status, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"reviews": reviews, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) approveReviewHandler(w http.ResponseWriter, r *http.Request) {
+	app.moderateReview(w, r, data.ReviewStatusApproved)
+}
+
+func (app *application) rejectReviewHandler(w http.ResponseWriter, r *http.Request) {
+	app.moderateReview(w, r, data.ReviewStatusRejected)
+}
+
+// moderateReview records an admin's decision on a review. A reason may be
+// given in the body and must be given when rejecting. Approvals may be sent
+// without a body.
+func (app *application) moderateReview(w http.ResponseWriter, r *http.Request, status string) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason"`
+	}
+
+	if status == data.ReviewStatusRejected || r.ContentLength != 0 {
+		err = app.readJSON(w, r, &input)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	v := validator.NewValidator()
+
+	if data.ValidateReviewModeration(v, status, input.Reason); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	review, err := app.models.Reviews.Moderate(id, status, user.ID, input.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"review": review}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -1,24 +1,82 @@
 package main
 
-import "net/http"
+import (
+	"net/http"
+
+	"github.com/vaidik-bajpai/ecommerce-api/internal/data"
+)
 
 func (app *application) routes() http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("POST /v1/users", app.createUserHandler)
 	mux.HandleFunc("POST /v1/users/login", app.userLoginHandler)
+	mux.HandleFunc("PUT /v1/users/activated", app.activateUserHandler)
+	mux.HandleFunc("PUT /v1/users/password", app.updateUserPasswordHandler)
+	mux.HandleFunc("PATCH /v1/users/me", app.requireAuthenticatedUser(app.updateCurrentUserHandler))
+
+	mux.HandleFunc("POST /v1/tokens/refresh", app.refreshTokenHandler)
+	mux.HandleFunc("POST /v1/tokens/logout", app.logoutHandler)
+	mux.HandleFunc("POST /v1/tokens/password-reset", app.createPasswordResetTokenHandler)
+
+	mux.HandleFunc("GET /v1/users/me/addresses", app.requireAuthenticatedUser(app.listAddressesHandler))
+	mux.HandleFunc("POST /v1/users/me/addresses", app.requireAuthenticatedUser(app.createAddressHandler))
+	mux.HandleFunc("GET /v1/users/me/addresses/{id}", app.requireAuthenticatedUser(app.showAddressHandler))
+	mux.HandleFunc("PATCH /v1/users/me/addresses/{id}", app.requireAuthenticatedUser(app.updateAddressHandler))
+	mux.HandleFunc("DELETE /v1/users/me/addresses/{id}", app.requireAuthenticatedUser(app.deleteAddressHandler))
 
 	mux.HandleFunc("GET /v1/products", app.searchProductHandler)
 	mux.HandleFunc("GET /v1/products/{id}", app.searchProductByIDHandler)
+	mux.HandleFunc("GET /v1/products/{id}/reviews", app.listReviewsHandler)
+	mux.HandleFunc("POST /v1/products/{id}/reviews", app.requireActivatedUser(app.createReviewHandler))
+
+	mux.HandleFunc("GET /v1/categories", app.listCategoriesHandler)
+	mux.HandleFunc("GET /v1/categories/{id}", app.showCategoryHandler)
+
+	mux.HandleFunc("POST /v1/admin/products", app.requirePermission(data.PermissionProductsWrite, app.createProductHandler))
+	mux.HandleFunc("PATCH /v1/admin/products/{id}", app.requirePermission(data.PermissionProductsWrite, app.updateProductHandler))
+	mux.HandleFunc("DELETE /v1/admin/products/{id}", app.requirePermission(data.PermissionProductsWrite, app.deleteProductHandler))
+	mux.HandleFunc("POST /v1/admin/products/{id}/variants", app.requirePermission(data.PermissionProductsWrite, app.createVariantHandler))
+	mux.HandleFunc("PATCH /v1/admin/variants/{id}", app.requirePermission(data.PermissionProductsWrite, app.updateVariantHandler))
+	mux.HandleFunc("DELETE /v1/admin/variants/{id}", app.requirePermission(data.PermissionProductsWrite, app.deleteVariantHandler))
+	mux.HandleFunc("POST /v1/admin/variants/{id}/stock", app.requirePermission(data.PermissionProductsWrite, app.adjustStockHandler))
+	mux.HandleFunc("GET /v1/admin/variants/{id}/stock", app.requirePermission(data.PermissionProductsWrite, app.listStockMovementsHandler))
+
+	mux.HandleFunc("POST /v1/admin/categories", app.requirePermission(data.PermissionProductsWrite, app.createCategoryHandler))
+	mux.HandleFunc("PATCH /v1/admin/categories/{id}", app.requirePermission(data.PermissionProductsWrite, app.updateCategoryHandler))
+	mux.HandleFunc("DELETE /v1/admin/categories/{id}", app.requirePermission(data.PermissionProductsWrite, app.deleteCategoryHandler))
+
+	mux.HandleFunc("POST /v1/admin/coupons", app.requirePermission(data.PermissionProductsWrite, app.createCouponHandler))
+
+	mux.HandleFunc("GET /v1/admin/reviews", app.requirePermission(data.PermissionReviewsWrite, app.listReviewsForModerationHandler))
+	mux.HandleFunc("POST /v1/admin/reviews/{id}/approve", app.requirePermission(data.PermissionReviewsWrite, app.approveReviewHandler))
+	mux.HandleFunc("POST /v1/admin/reviews/{id}/reject", app.requirePermission(data.PermissionReviewsWrite, app.rejectReviewHandler))
+
+	mux.HandleFunc("PATCH /v1/admin/orders/{id}/status", app.requirePermission(data.PermissionOrdersWrite, app.updateOrderStatusHandler))
+
+	mux.HandleFunc("GET /v1/admin/payment-events", app.requirePermission(data.PermissionOrdersWrite, app.listPaymentEventsHandler))
+	mux.HandleFunc("POST /v1/admin/payment-events/{id}/replay", app.requirePermission(data.PermissionOrdersWrite, app.replayPaymentEventHandler))
+
+	mux.HandleFunc("PUT /v1/admin/users/{id}/permissions", app.requirePermission(data.PermissionUsersWrite, app.updateUserPermissionsHandler))
+
+	mux.HandleFunc("GET /v1/cart", app.requireAuthenticatedUser(app.showCartHandler))
+	mux.HandleFunc("POST /v1/cart/items", app.requireAuthenticatedUser(app.addToCartHandler))
+	mux.HandleFunc("PUT /v1/cart/items/{id}", app.requireAuthenticatedUser(app.setCartItemQuantityHandler))
+	mux.HandleFunc("POST /v1/cart/items/{id}/increment", app.requireAuthenticatedUser(app.incrementCartItemHandler))
+	mux.HandleFunc("POST /v1/cart/items/{id}/decrement", app.requireAuthenticatedUser(app.decrementCartItemHandler))
+	mux.HandleFunc("DELETE /v1/cart/items/{id}", app.requireAuthenticatedUser(app.removeItemHandler))
+
+	mux.HandleFunc("POST /v1/cart/coupon", app.requireAuthenticatedUser(app.applyCouponHandler))
+	mux.HandleFunc("DELETE /v1/cart/coupon", app.requireAuthenticatedUser(app.removeCouponHandler))
 
-	mux.HandleFunc("POST /v1/admin/products", app.requireAuthenticatedUser(app.createProductHandler))
-	mux.HandleFunc("DELETE /v1/admin/products/{id}", app.requireAuthenticatedUser(app.deleteProductHandler))
+	mux.HandleFunc("POST /v1/cart/checkout", app.requireActivatedUser(app.cartCheckoutHandler))
+	mux.HandleFunc("POST /v1/instantbuy", app.requireActivatedUser(app.instantBuyHandler))
 
-	mux.HandleFunc("GET /v1/addtocart", app.requireAuthenticatedUser(app.addToCartHandler))
-	mux.HandleFunc("GET /v1/removefromcart", app.requireAuthenticatedUser(app.removeItemHandler))
+	mux.HandleFunc("POST /v1/webhooks/payments", app.paymentWebhookHandler)
 
-	mux.HandleFunc("GET /v1/cartcheckout", app.requireAuthenticatedUser(app.cartCheckoutHandler))
-	mux.HandleFunc("GET /v1/instantbuy", app.requireAuthenticatedUser(app.instantBuyHandler))
+	mux.HandleFunc("GET /v1/orders", app.requireAuthenticatedUser(app.listOrdersHandler))
+	mux.HandleFunc("GET /v1/orders/{id}", app.requireAuthenticatedUser(app.showOrderHandler))
+	mux.HandleFunc("POST /v1/orders/{id}/cancel", app.requireAuthenticatedUser(app.cancelOrderHandler))
 
 	return app.authenticate(mux)
 }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// serve runs the HTTP server until it receives SIGINT or SIGTERM. It then
+// stops accepting connections, lets in-flight requests finish and waits for
+// background work, such as outgoing emails, before returning.
+func (app *application) serve() error {
+	srv := &http.Server{
+		Addr:        fmt.Sprintf(":%d", app.config.port),
+		Handler:     app.routes(),
+		IdleTimeout: 10 * time.Second,
+		ReadTimeout: 30 * time.Second,
+	}
+
+	shutdownError := make(chan error)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+
+		app.logger.Printf("shutting down server, signal %s", s)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			shutdownError <- err
+			return
+		}
+
+		app.logger.Printf("completing background tasks, addr %s", srv.Addr)
+
+		app.wg.Wait()
+		shutdownError <- nil
+	}()
+
+	app.logger.Printf("starting %s server on %s", app.config.env, srv.Addr)
+
+	err := srv.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	err = <-shutdownError
+	if err != nil {
+		return err
+	}
+
+	app.logger.Printf("stopped server, addr %s", srv.Addr)
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/vaidik-bajpai/ecommerce-api/internal/data"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/validator"
+)
+
+func (app *application) createVariantHandler(w http.ResponseWriter, r *http.Request) {
+	productID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Products.Get(productID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		SKU        string            `json:"sku"`
+		Attributes map[string]string `json:"attributes"`
+		Price      uint64            `json:"price"`
+		Image      *string           `json:"image"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	variant := &data.Variant{
+		ProductID:  productID,
+		SKU:        input.SKU,
+		Attributes: input.Attributes,
+		Price:      input.Price,
+		Image:      input.Image,
+	}
+
+	if variant.Attributes == nil {
+		variant.Attributes = map[string]string{}
+	}
+
+	v := validator.NewValidator()
+
+	if data.ValidateVariant(v, variant); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Variants.Insert(variant)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateSKU):
+			v.AddErrors("sku", "a variant with this sku already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"variant": variant}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateVariantHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	variant, err := app.models.Variants.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !app.expectedVersionMatches(r, variant.Version) {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	var input struct {
+		SKU        *string           `json:"sku"`
+		Attributes map[string]string `json:"attributes"`
+		Price      *uint64           `json:"price"`
+		Image      *string           `json:"image"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.SKU != nil {
+		variant.SKU = *input.SKU
+	}
+	if input.Attributes != nil {
+		variant.Attributes = input.Attributes
+	}
+	if input.Price != nil {
+		variant.Price = *input.Price
+	}
+	if input.Image != nil {
+		variant.Image = input.Image
+	}
+
+	v := validator.NewValidator()
+
+	if data.ValidateVariant(v, variant); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Variants.Update(variant)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateSKU):
+			v.AddErrors("sku", "a variant with this sku already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"variant": variant}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteVariantHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	err = app.models.Variants.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "variant successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
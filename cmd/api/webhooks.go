@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/vaidik-bajpai/ecommerce-api/internal/data"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/payments"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/validator"
+)
+
+// paymentWebhookHandler receives events from the payment provider. Every
+// event is stored before it is acted on, and an event ID that was already
+// received is acknowledged without being acted on again, so redeliveries are
+// harmless. Events that do not apply to anything are kept for replay rather
+// than rejected, since the provider would only keep retrying them.
+func (app *application) paymentWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	maxBytes := 1_048_576
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("body must not be larger than %d bytes", maxBytes))
+		return
+	}
+
+	if !payments.VerifySignature(app.config.payments.webhookSecret, payload, r.Header.Get(payments.SignatureHeader)) {
+		app.invalidSignatureResponse(w, r)
+		return
+	}
+
+	var input payments.Event
+
+	err = json.Unmarshal(payload, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, errors.New("body contains badly-formed JSON"))
+		return
+	}
+
+	v := validator.NewValidator()
+
+	v.Check(input.ID != "", "id", "must be provided")
+	v.Check(len(input.ID) <= 255, "id", "must not be more than 255 bytes long")
+	v.Check(input.Type != "", "type", "must be provided")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	event := &data.PaymentEvent{
+		EventID: input.ID,
+		Type:    input.Type,
+		Payload: payload,
+	}
+
+	err = app.models.PaymentEvents.Insert(event)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicatePaymentEvent):
+			event, err = app.models.PaymentEvents.GetByEventID(input.ID)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+
+			// A delivery that failed on our side, or was abandoned half way,
+			// is worth another go when the provider retries it. Anything
+			// else has been dealt with or is being dealt with right now.
+			if event.Status == data.PaymentEventUnhandled || !event.Replayable() {
+				err = app.writeJSON(w, http.StatusOK, envelope{"event": event}, nil)
+				if err != nil {
+					app.serverErrorResponse(w, r, err)
+				}
+				return
+			}
+		default:
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.processPaymentEvent(event, input)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"event": event}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// processPaymentEvent acts on a stored event and records the outcome on it.
+// A confirmed payment marks its order paid and a failed one marks it failed,
+// which releases its stock. An event for an order that is no longer waiting
+// on payment, or a confirmation for an amount other than the order's total,
+// is left unhandled for someone to look at. The returned error is only for
+// failures on our side, which also mark the event failed.
+func (app *application) processPaymentEvent(event *data.PaymentEvent, input payments.Event) error {
+	var to, attemptStatus string
+
+	switch input.Type {
+	case payments.EventPaymentSucceeded:
+		to, attemptStatus = data.OrderStatusPaid, payments.StatusApproved
+	case payments.EventPaymentFailed:
+		to, attemptStatus = data.OrderStatusPaymentFailed, payments.StatusDeclined
+	default:
+		return app.models.PaymentEvents.Resolve(event, data.PaymentEventUnhandled, nil, "unknown event type")
+	}
+
+	order, err := app.models.Orders.GetByReference(input.Data.OrderReference)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return app.models.PaymentEvents.Resolve(event, data.PaymentEventUnhandled, nil, "no order with this reference")
+		default:
+			return app.failPaymentEvent(event, nil, err)
+		}
+	}
+
+	if order.Status == to {
+		return app.models.PaymentEvents.Resolve(event, data.PaymentEventProcessed, &order.ID, "")
+	}
+
+	if order.Status != data.OrderStatusPendingPayment {
+		reason := fmt.Sprintf("order is %s", order.Status)
+		return app.models.PaymentEvents.Resolve(event, data.PaymentEventUnhandled, &order.ID, reason)
+	}
+
+	if to == data.OrderStatusPaid && input.Data.Amount != int64(order.Total) {
+		reason := fmt.Sprintf("amount %d does not match the order total of %d", input.Data.Amount, order.Total)
+		return app.models.PaymentEvents.Resolve(event, data.PaymentEventUnhandled, &order.ID, reason)
+	}
+
+	attempt := &data.PaymentAttempt{
+		OrderID:   order.ID,
+		Gateway:   app.payments.Name(),
+		Operation: data.PaymentOperationAuthorize,
+		Amount:    input.Data.Amount,
+		Status:    attemptStatus,
+	}
+
+	// A failure may not say how much was asked for, but it can only have
+	// been the order's total.
+	if attempt.Amount == 0 {
+		attempt.Amount = int64(order.Total)
+	}
+
+	if input.Data.TransactionID != "" {
+		attempt.TransactionID = &input.Data.TransactionID
+	}
+
+	if input.Data.Message != "" {
+		attempt.Error = &input.Data.Message
+	}
+
+	err = app.models.PaymentAttempts.Insert(attempt)
+	if err != nil {
+		return app.failPaymentEvent(event, &order.ID, err)
+	}
+
+	note := "payment confirmed by provider"
+	if to == data.OrderStatusPaymentFailed {
+		note = "payment failed at provider"
+		if input.Data.Message != "" {
+			note = input.Data.Message
+		}
+	}
+
+	_, err = app.models.Orders.Transition(order.ID, to, nil, note)
+	if err != nil {
+		return app.failPaymentEvent(event, &order.ID, err)
+	}
+
+	return app.models.PaymentEvents.Resolve(event, data.PaymentEventProcessed, &order.ID, "")
+}
+
+func (app *application) failPaymentEvent(event *data.PaymentEvent, orderID *int64, cause error) error {
+	err := app.models.PaymentEvents.Resolve(event, data.PaymentEventFailed, orderID, cause.Error())
+	if err != nil {
+		app.logger.Println(err)
+	}
+
+	return cause
+}
+
+func (app *application) listPaymentEventsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Status string
+		data.Filters
+	}
+
+	qs := r.URL.Query()
+
+	v := validator.NewValidator()
+
+	input.Status = app.readString(qs, "status", data.PaymentEventUnhandled, v)
+
+	input.Page = app.readInt(qs, "page", 1, v)
+	input.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Sort = app.readString(qs, "sort", "id", v)
+	input.Filters.SortSafeList = []string{"id", "-id"}
+
+	v.Check(validator.In(input.Status, data.PaymentEventStatuses...), "status", "invalid status value")
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	events, metadata, err := app.models.PaymentEvents.GetAll(input.Status, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"events": events, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// replayPaymentEventHandler acts on a stored event again, for events that
+// arrived before the order they refer to, failed on our side or were
+// abandoned half way.
+func (app *application) replayPaymentEventHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	event, err := app.models.PaymentEvents.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !event.Replayable() {
+		app.errorResponse(w, r, http.StatusConflict, "only unhandled, failed or abandoned events can be replayed")
+		return
+	}
+
+	var input payments.Event
+
+	err = json.Unmarshal(event.Payload, &input)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.processPaymentEvent(event, input)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"event": event}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -2,29 +2,86 @@ package data
 
 import (
 	"context"
+	"errors"
 	"time"
 
+	"github.com/steebchen/prisma-client-go/runtime/transaction"
 	"github.com/vaidik-bajpai/ecommerce-api/internal/prisma/db"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/validator"
 )
 
 type Address struct {
-	ID      int
-	House   *string
-	Street  *string
-	City    *string
-	Pincode *string
-	UserID  int
+	ID        int     `json:"id"`
+	House     *string `json:"house"`
+	Street    *string `json:"street"`
+	City      *string `json:"city"`
+	Pincode   *string `json:"pincode"`
+	IsDefault bool    `json:"is_default"`
+	UserID    int     `json:"user_id"`
+}
+
+func newAddress(record *db.AddressModel) *Address {
+	return &Address{
+		ID:        record.ID,
+		House:     &record.House,
+		Street:    &record.Street,
+		City:      &record.City,
+		Pincode:   &record.Pincode,
+		IsDefault: record.IsDefault,
+		UserID:    record.UserID,
+	}
+}
+
+func ValidateAddress(v *validator.Validator, address *Address) {
+	v.Check(*address.House != "", "house", "must be provided")
+	v.Check(len(*address.House) <= 100, "house", "must not be more than 100 bytes")
+
+	v.Check(*address.Street != "", "street", "must be provided")
+	v.Check(len(*address.Street) <= 100, "street", "must not be more than 100 bytes")
+
+	v.Check(*address.City != "", "city", "must be provided")
+	v.Check(len(*address.City) <= 50, "city", "must not be more than 50 bytes")
+
+	v.Check(*address.Pincode != "", "pincode", "must be provided")
+	v.Check(validator.Matches(*address.Pincode, validator.PincodeRX), "pincode", "must be a valid pincode")
 }
 
 type AddressModel struct {
 	DB *db.PrismaClient
 }
 
+// clearDefault returns an operation that unsets the default flag on all of
+// the user's addresses, to run in the same transaction that sets a new one.
+func (m AddressModel) clearDefault(userId int) db.AddressManyTxResult {
+	return m.DB.Address.FindMany(
+		db.Address.UserID.Equals(userId),
+		db.Address.IsDefault.Equals(true),
+	).Update(
+		db.Address.IsDefault.Set(false),
+	).Tx()
+}
+
+// AddAddress saves a new address for the user. The first address a user adds
+// becomes their default address.
 func (m AddressModel) AddAddress(userId int, address *Address) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	newAddress, err := m.DB.Address.CreateOne(
+	_, err := m.DB.Address.FindFirst(
+		db.Address.UserID.Equals(userId),
+		db.Address.ArchivedAt.IsNull(),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			address.IsDefault = true
+		default:
+			return err
+		}
+	}
+
+	createAddress := m.DB.Address.CreateOne(
 		db.Address.House.Set(*address.House),
 		db.Address.Street.Set(*address.Street),
 		db.Address.City.Set(*address.City),
@@ -32,28 +89,163 @@ func (m AddressModel) AddAddress(userId int, address *Address) error {
 		db.Address.User.Link(
 			db.User.ID.Equals(userId),
 		),
-	).Exec(ctx)
+		db.Address.IsDefault.Set(address.IsDefault),
+	).Tx()
+
+	if address.IsDefault {
+		err = m.DB.Prisma.Transaction(m.clearDefault(userId), createAddress).Exec(ctx)
+	} else {
+		err = m.DB.Prisma.Transaction(createAddress).Exec(ctx)
+	}
 
 	if err != nil {
 		return err
 	}
 
+	newAddress := createAddress.Result()
+
 	address.ID = newAddress.ID
+	address.UserID = newAddress.UserID
 
 	return nil
 }
 
-func (m AddressModel) RemoveAddress(addressID int) error {
+func (m AddressModel) GetAllForUser(userId int) ([]*Address, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	_, err := m.DB.Product.FindUnique(
-		db.Product.ID.Equals(addressID),
-	).Delete().Exec(ctx)
+	records, err := m.DB.Address.FindMany(
+		db.Address.UserID.Equals(userId),
+		db.Address.ArchivedAt.IsNull(),
+	).OrderBy(
+		db.Address.ID.Order(db.SortOrderAsc),
+	).Exec(ctx)
 
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := []*Address{}
+	for _, record := range records {
+		addresses = append(addresses, newAddress(&record))
+	}
+
+	return addresses, nil
+}
+
+func (m AddressModel) GetForUser(addressID, userId int) (*Address, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	record, err := m.DB.Address.FindFirst(
+		db.Address.ID.Equals(addressID),
+		db.Address.UserID.Equals(userId),
+		db.Address.ArchivedAt.IsNull(),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return newAddress(record), nil
+}
+
+// UpdateAddress saves the address, which must already have been fetched with
+// GetForUser so that it is known to belong to the user.
+func (m AddressModel) UpdateAddress(address *Address) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	updateAddress := m.DB.Address.FindUnique(
+		db.Address.ID.Equals(address.ID),
+	).Update(
+		db.Address.House.Set(*address.House),
+		db.Address.Street.Set(*address.Street),
+		db.Address.City.Set(*address.City),
+		db.Address.Pincode.Set(*address.Pincode),
+		db.Address.IsDefault.Set(address.IsDefault),
+	).Tx()
+
+	var err error
+	if address.IsDefault {
+		err = m.DB.Prisma.Transaction(m.clearDefault(address.UserID), updateAddress).Exec(ctx)
+	} else {
+		err = m.DB.Prisma.Transaction(updateAddress).Exec(ctx)
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemoveAddress takes one of the user's addresses out of their address book.
+// The address is archived rather than deleted, so orders shipped to it still
+// say where they went, but it is no longer listed or used for new orders. If
+// the default address is removed, the user's oldest remaining address becomes
+// the default, so checkout keeps working without one being given.
+func (m AddressModel) RemoveAddress(addressID, userId int) error {
+	address, err := m.GetForUser(addressID, userId)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	archive := m.DB.Address.FindMany(
+		db.Address.ID.Equals(addressID),
+		db.Address.UserID.Equals(userId),
+		db.Address.ArchivedAt.IsNull(),
+	).Update(
+		db.Address.ArchivedAt.Set(time.Now()),
+		db.Address.IsDefault.Set(false),
+	).Tx()
+
+	ops := []transaction.Param{archive}
+
+	if address.IsDefault {
+		next, err := m.DB.Address.FindFirst(
+			db.Address.UserID.Equals(userId),
+			db.Address.ArchivedAt.IsNull(),
+			db.Address.Not(
+				db.Address.ID.Equals(addressID),
+			),
+		).OrderBy(
+			db.Address.ID.Order(db.SortOrderAsc),
+		).Exec(ctx)
+
+		switch {
+		case err == nil:
+			ops = append(ops, m.DB.Address.FindUnique(
+				db.Address.ID.Equals(next.ID),
+			).Update(
+				db.Address.IsDefault.Set(true),
+			).Tx())
+		case !errors.Is(err, db.ErrNotFound):
+			return err
+		}
+	}
+
+	err = m.DB.Prisma.Transaction(ops...).Exec(ctx)
 	if err != nil {
 		return err
 	}
 
+	if archive.Result().Count == 0 {
+		return ErrRecordNotFound
+	}
+
 	return nil
 }
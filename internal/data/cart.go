@@ -3,56 +3,463 @@ package data
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
 
+	"github.com/shopspring/decimal"
+	"github.com/steebchen/prisma-client-go/runtime/transaction"
 	"github.com/vaidik-bajpai/ecommerce-api/internal/prisma/db"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/validator"
 )
 
 type Cart struct {
-	ID        int `json:"id"`
-	UserID    int `json:"user_id"`
-	ProductID int `json:"product_id"`
+	ID        int             `json:"id"`
+	UserID    int             `json:"user_id"`
+	Items     []CartItem      `json:"items"`
+	ItemCount int             `json:"item_count"`
+	Total     decimal.Decimal `json:"total"`
+	Shipping  decimal.Decimal `json:"shipping"`
+	Coupon    *string         `json:"coupon"`
+	Discount  decimal.Decimal `json:"discount"`
+	Payable   decimal.Decimal `json:"payable"`
+}
+
+type CartItem struct {
+	ID         int               `json:"id"`
+	VariantID  int               `json:"variant_id"`
+	ProductID  int               `json:"product_id"`
+	Name       string            `json:"name"`
+	SKU        string            `json:"sku"`
+	Attributes map[string]string `json:"attributes"`
+	Image      *string           `json:"image"`
+	Quantity   int               `json:"quantity"`
+	UnitPrice  decimal.Decimal   `json:"unit_price"`
+	Subtotal   decimal.Decimal   `json:"subtotal"`
 }
 
 type CartModel struct {
 	DB *db.PrismaClient
 }
 
-func (m CartModel) AddToCart(userId, productId int) error {
+// maxCartQuantity is the most units of one variant a cart can hold.
+const maxCartQuantity = 100
+
+// cartQuantityConstraint is the name of the check constraint that keeps the
+// quantity of a cart item between 1 and maxCartQuantity. Adding to an item
+// already in the cart is an increment in the database, so the limit on the
+// resulting quantity is only enforced there.
+const cartQuantityConstraint = "CartItem_quantity_check"
+
+func isCartQuantityViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), cartQuantityConstraint)
+}
+
+func ValidateCartQuantity(v *validator.Validator, quantity int) {
+	v.Check(quantity > 0, "quantity", "must be greater than zero")
+	v.Check(quantity <= maxCartQuantity, "quantity", "must not be more than 100")
+}
+
+// newCart builds a Cart from a record fetched with its items, their variants
+// and their products, working out the line subtotals, item count and grand
+// total. A cart with items is charged the shipping fee until a coupon says
+// otherwise.
+func newCart(record *db.CartModel) (*Cart, error) {
+	cart := &Cart{
+		ID:       record.ID,
+		UserID:   record.UserID,
+		Items:    []CartItem{},
+		Total:    decimal.Zero,
+		Shipping: decimal.Zero,
+		Discount: decimal.Zero,
+	}
+
+	for _, item := range record.Items() {
+		variant, err := newVariant(item.Variant())
+		if err != nil {
+			return nil, err
+		}
+
+		product := item.Variant().Product()
+
+		unitPrice := decimal.NewFromInt(int64(variant.Price))
+		subtotal := unitPrice.Mul(decimal.NewFromInt(int64(item.Quantity)))
+
+		cartItem := CartItem{
+			ID:         item.ID,
+			VariantID:  item.VariantID,
+			ProductID:  product.ID,
+			Name:       product.Name,
+			SKU:        variant.SKU,
+			Attributes: variant.Attributes,
+			Image:      variant.Image,
+			Quantity:   item.Quantity,
+			UnitPrice:  unitPrice,
+			Subtotal:   subtotal,
+		}
+
+		if image, ok := product.Image(); ok && cartItem.Image == nil {
+			cartItem.Image = &image
+		}
+
+		cart.Items = append(cart.Items, cartItem)
+		cart.ItemCount += item.Quantity
+		cart.Total = cart.Total.Add(subtotal)
+	}
+
+	if len(cart.Items) > 0 {
+		cart.Shipping = shippingFor(nil)
+	}
+
+	cart.Payable = cart.Total.Add(cart.Shipping)
+
+	return cart, nil
+}
+
+// cartLines turns the items of a cart record into order lines.
+func cartLines(record *db.CartModel) []orderLine {
+	var lines []orderLine
+
+	for _, item := range record.Items() {
+		lines = append(lines, newOrderLine(item.Variant(), item.Quantity))
+	}
+
+	return lines
+}
+
+// removeOrdered returns the operations that take the units that were ordered
+// out of the cart. An item whose quantity went up while the order was being
+// placed keeps the extra units; the others are removed.
+func removeOrdered(client *db.PrismaClient, items []db.CartItemModel) []transaction.Param {
+	var ops []transaction.Param
+
+	for _, item := range items {
+		ops = append(ops,
+			client.Prisma.ExecuteRaw(
+				`DELETE FROM "CartItem" WHERE "id" = $1 AND "quantity" <= $2`,
+				item.ID, item.Quantity,
+			).Tx(),
+			client.Prisma.ExecuteRaw(
+				`UPDATE "CartItem" SET "quantity" = "quantity" - $2 WHERE "id" = $1 AND "quantity" > $2`,
+				item.ID, item.Quantity,
+			).Tx(),
+		)
+	}
+
+	return ops
+}
+
+func (m CartModel) Get(userId int) (*Cart, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	record, err := m.DB.Cart.FindUnique(
+		db.Cart.UserID.Equals(userId),
+	).With(
+		db.Cart.Items.Fetch().With(
+			db.CartItem.Variant.Fetch().With(
+				db.ProductVariant.Product.Fetch(),
+			),
+		),
+		db.Cart.Coupon.Fetch(),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return &Cart{UserID: userId, Items: []CartItem{}, Total: decimal.Zero, Shipping: decimal.Zero, Discount: decimal.Zero, Payable: decimal.Zero}, nil
+		default:
+			return nil, err
+		}
+	}
+
+	cart, err := newCart(record)
+	if err != nil {
+		return nil, err
+	}
+
+	// A coupon that no longer applies, say because items were removed, stays
+	// on the cart but gives no discount. Checkout will refuse it.
+	if coupon, ok := record.Coupon(); ok {
+		cart.Coupon = &coupon.Code
+
+		lines := cartLines(record)
+
+		discount, err := CouponModel{DB: m.DB}.discountFor(ctx, coupon, int64(userId), lines)
+		if err != nil && !isCouponRuleError(err) {
+			return nil, err
+		}
+
+		if err == nil {
+			cart.Discount = discount
+			cart.Shipping = shippingFor(coupon)
+			cart.Payable = cart.Total.Add(cart.Shipping).Sub(discount)
+		}
+	}
+
+	return cart, nil
+}
+
+// ApplyCoupon puts the coupon with the given code on the user's cart, as long
+// as its rules allow it to be used on what is in the cart right now.
+func (m CartModel) ApplyCoupon(userId int, code string) (*Cart, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	_, err := m.DB.Cart.UpsertOne(
+	coupon, err := m.DB.Coupon.FindUnique(
+		db.Coupon.Code.Equals(code),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	record, err := m.DB.Cart.FindUnique(
+		db.Cart.UserID.Equals(userId),
+	).With(
+		db.Cart.Items.Fetch().With(
+			db.CartItem.Variant.Fetch().With(
+				db.ProductVariant.Product.Fetch(),
+			),
+		),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return nil, ErrEmptyCart
+		default:
+			return nil, err
+		}
+	}
+
+	if len(record.Items()) == 0 {
+		return nil, ErrEmptyCart
+	}
+
+	lines := cartLines(record)
+
+	_, err = CouponModel{DB: m.DB}.discountFor(ctx, coupon, int64(userId), lines)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = m.DB.Cart.FindUnique(
+		db.Cart.ID.Equals(record.ID),
+	).Update(
+		db.Cart.Coupon.Link(
+			db.Coupon.ID.Equals(coupon.ID),
+		),
+	).Exec(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Get(userId)
+}
+
+func (m CartModel) RemoveCoupon(userId int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.Cart.FindMany(
+		db.Cart.UserID.Equals(userId),
+	).Update(
+		db.Cart.CouponID.SetOptional(nil),
+	).Exec(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m CartModel) getOrCreateCart(ctx context.Context, userId int) (*db.CartModel, error) {
+	return m.DB.Cart.UpsertOne(
 		db.Cart.UserID.Equals(userId),
 	).Create(
 		db.Cart.User.Link(
 			db.User.ID.Equals(userId),
 		),
-		db.Cart.Products.Link(
-			db.Product.ID.Equals(productId),
+	).Update().Exec(ctx)
+}
+
+func (m CartModel) getItem(ctx context.Context, userId, variantId int) (*db.CartItemModel, error) {
+	item, err := m.DB.CartItem.FindFirst(
+		db.CartItem.VariantID.Equals(variantId),
+		db.CartItem.Cart.Where(
+			db.Cart.UserID.Equals(userId),
 		),
-	).Update(
-		db.Cart.UserID.Set(userId),
-		db.Cart.Products.Link(
-			db.Product.ID.Equals(productId),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return item, nil
+}
+
+// AddToCart puts quantity units of the variant in the user's cart, adding to
+// the quantity already there if the variant is in the cart.
+func (m CartModel) AddToCart(userId, variantId, quantity int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ProductVariant.FindFirst(
+		db.ProductVariant.ID.Equals(variantId),
+		db.ProductVariant.ArchivedAt.IsNull(),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	cart, err := m.getOrCreateCart(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.DB.CartItem.UpsertOne(
+		db.CartItem.CartIDVariantID(
+			db.CartItem.CartID.Equals(cart.ID),
+			db.CartItem.VariantID.Equals(variantId),
+		),
+	).Create(
+		db.CartItem.Cart.Link(
+			db.Cart.ID.Equals(cart.ID),
 		),
+		db.CartItem.Variant.Link(
+			db.ProductVariant.ID.Equals(variantId),
+		),
+		db.CartItem.Quantity.Set(quantity),
+	).Update(
+		db.CartItem.Quantity.Increment(quantity),
 	).Exec(ctx)
 
+	if err != nil {
+		switch {
+		case isCartQuantityViolation(err):
+			return ErrCartQuantityLimit
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m CartModel) IncrementItem(userId, variantId, quantity int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	item, err := m.getItem(ctx, userId, variantId)
 	if err != nil {
 		return err
 	}
 
-	/* if err != nil {
+	_, err = m.DB.CartItem.FindUnique(
+		db.CartItem.ID.Equals(item.ID),
+	).Update(
+		db.CartItem.Quantity.Increment(quantity),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case isCartQuantityViolation(err):
+			return ErrCartQuantityLimit
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DecrementItem takes quantity units of the variant out of the user's cart.
+// The entry is removed once its quantity would drop to zero.
+func (m CartModel) DecrementItem(userId, variantId, quantity int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	item, err := m.getItem(ctx, userId, variantId)
+	if err != nil {
+		return err
+	}
+
+	if item.Quantity <= quantity {
+		_, err = m.DB.CartItem.FindUnique(
+			db.CartItem.ID.Equals(item.ID),
+		).Delete().Exec(ctx)
+	} else {
+		_, err = m.DB.CartItem.FindUnique(
+			db.CartItem.ID.Equals(item.ID),
+		).Update(
+			db.CartItem.Quantity.Decrement(quantity),
+		).Exec(ctx)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m CartModel) RemoveItem(userId, variantId int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	item, err := m.getItem(ctx, userId, variantId)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.DB.CartItem.FindUnique(
+		db.CartItem.ID.Equals(item.ID),
+	).Delete().Exec(ctx)
+
+	if err != nil {
 		switch {
 		case errors.Is(err, db.ErrNotFound):
-			createErr := m.CreateCart(userId)
-			if createErr != nil {
-				return createErr
-			}
+			return ErrRecordNotFound
 		default:
 			return err
 		}
-	} */
+	}
+
+	return nil
+}
+
+func (m CartModel) SetItemQuantity(userId, variantId, quantity int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	item, err := m.getItem(ctx, userId, variantId)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.DB.CartItem.FindUnique(
+		db.CartItem.ID.Equals(item.ID),
+	).Update(
+		db.CartItem.Quantity.Set(quantity),
+	).Exec(ctx)
 
 	if err != nil {
 		return err
@@ -90,3 +497,171 @@ func (m CartModel) CreateCart(userId int) error {
 
 	return nil
 }
+
+func (m CartModel) Checkout(userID, addressID, paymentID int64) (*Order, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	cart, err := m.DB.Cart.FindUnique(
+		db.Cart.UserID.Equals(int(userID)),
+	).With(
+		db.Cart.Items.Fetch().With(
+			db.CartItem.Variant.Fetch().With(
+				db.ProductVariant.Product.Fetch(),
+			),
+		),
+		db.Cart.Coupon.Fetch(),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return nil, ErrEmptyCart
+		default:
+			return nil, err
+		}
+	}
+
+	if len(cart.Items()) == 0 {
+		return nil, ErrEmptyCart
+	}
+
+	lines := cartLines(cart)
+
+	// Only the units that were priced are taken out of the cart, so anything
+	// added while the checkout is running stays there.
+	extra := removeOrdered(m.DB, cart.Items())
+
+	var applied *appliedCoupon
+
+	if coupon, ok := cart.Coupon(); ok {
+		discount, err := CouponModel{DB: m.DB}.discountFor(ctx, coupon, userID, lines)
+		if err != nil {
+			return nil, err
+		}
+
+		applied = &appliedCoupon{coupon: coupon, discount: discount}
+
+		extra = append(extra, m.DB.Cart.FindMany(
+			db.Cart.ID.Equals(cart.ID),
+		).Update(
+			db.Cart.CouponID.SetOptional(nil),
+		).Tx())
+	}
+
+	orders := OrderModel{DB: m.DB}
+
+	return orders.place(ctx, userID, addressID, paymentID, lines, applied, extra...)
+}
+
+// restoredQuantity is how many units of a variant the cart holds once an
+// order's units are put back on top of the ones already there. It never goes
+// over maxCartQuantity, so restoring a large order cannot fail the limit and
+// lose every item.
+func restoredQuantity(inCart, ordered int) int {
+	return min(inCart+ordered, maxCartQuantity)
+}
+
+// RestoreOrder puts the items and coupon of one of the user's orders whose
+// payment failed back in their cart, so they can check out again with another
+// payment method. Items whose variant has since been removed are left out,
+// and each item is capped at the cart limit. Orders in any other status are
+// reported as not found.
+func (m CartModel) RestoreOrder(orderID, userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	order, err := m.DB.Orders.FindFirst(
+		db.Orders.ID.Equals(int(orderID)),
+		db.Orders.UserID.Equals(int(userID)),
+		db.Orders.Status.Equals(OrderStatusPaymentFailed),
+	).With(
+		db.Orders.Items.Fetch(
+			db.OrderItem.Variant.Where(
+				db.ProductVariant.ArchivedAt.IsNull(),
+			),
+		),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	cart, err := m.getOrCreateCart(ctx, int(userID))
+	if err != nil {
+		return err
+	}
+
+	inCart, err := m.DB.CartItem.FindMany(
+		db.CartItem.CartID.Equals(cart.ID),
+	).Exec(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	quantities := map[int]int{}
+	for _, item := range inCart {
+		quantities[item.VariantID] = item.Quantity
+	}
+
+	var ops []transaction.Param
+
+	for _, item := range order.Items() {
+		variantID, ok := item.VariantID()
+		if !ok {
+			continue
+		}
+
+		quantity := restoredQuantity(quantities[variantID], item.Quantity)
+		quantities[variantID] = quantity
+
+		ops = append(ops, m.DB.CartItem.UpsertOne(
+			db.CartItem.CartIDVariantID(
+				db.CartItem.CartID.Equals(cart.ID),
+				db.CartItem.VariantID.Equals(variantID),
+			),
+		).Create(
+			db.CartItem.Cart.Link(
+				db.Cart.ID.Equals(cart.ID),
+			),
+			db.CartItem.Variant.Link(
+				db.ProductVariant.ID.Equals(variantID),
+			),
+			db.CartItem.Quantity.Set(quantity),
+		).Update(
+			db.CartItem.Quantity.Set(quantity),
+		).Tx())
+	}
+
+	if couponID, ok := order.CouponID(); ok {
+		ops = append(ops, m.DB.Cart.FindUnique(
+			db.Cart.ID.Equals(cart.ID),
+		).Update(
+			db.Cart.Coupon.Link(
+				db.Coupon.ID.Equals(couponID),
+			),
+		).Tx())
+	}
+
+	if len(ops) == 0 {
+		return nil
+	}
+
+	err = m.DB.Prisma.Transaction(ops...).Exec(ctx)
+	if err != nil {
+		switch {
+		case isCartQuantityViolation(err):
+			return ErrCartQuantityLimit
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
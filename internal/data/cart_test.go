@@ -0,0 +1,52 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/vaidik-bajpai/ecommerce-api/internal/validator"
+)
+
+func TestValidateCartQuantity(t *testing.T) {
+	tests := []struct {
+		quantity int
+		want     bool
+	}{
+		{quantity: 1, want: true},
+		{quantity: 50, want: true},
+		{quantity: 100, want: true},
+		{quantity: 101},
+		{quantity: 0},
+		{quantity: -1},
+	}
+
+	for _, tt := range tests {
+		v := validator.NewValidator()
+		ValidateCartQuantity(v, tt.quantity)
+
+		if v.Valid() != tt.want {
+			t.Errorf("quantity %d: got valid %t; want %t (errors: %v)", tt.quantity, v.Valid(), tt.want, v.Errors)
+		}
+	}
+}
+
+func TestRestoredQuantity(t *testing.T) {
+	tests := []struct {
+		name            string
+		inCart, ordered int
+		want            int
+	}{
+		{name: "empty cart", ordered: 3, want: 3},
+		{name: "added to what is there", inCart: 2, ordered: 3, want: 5},
+		{name: "up to the limit", inCart: 60, ordered: 40, want: 100},
+		{name: "over the limit", inCart: 95, ordered: 10, want: 100},
+		{name: "full cart", inCart: 100, ordered: 100, want: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := restoredQuantity(tt.inCart, tt.ordered); got != tt.want {
+				t.Errorf("got %d; want %d", got, tt.want)
+			}
+		})
+	}
+}
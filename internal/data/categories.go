@@ -0,0 +1,278 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/vaidik-bajpai/ecommerce-api/internal/prisma/db"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/validator"
+)
+
+type Category struct {
+	ID       int64       `json:"id"`
+	Name     string      `json:"name"`
+	Slug     string      `json:"slug"`
+	ParentID *int64      `json:"parent_id"`
+	Children []*Category `json:"children,omitempty"`
+}
+
+func newCategory(record *db.CategoryModel) *Category {
+	category := &Category{
+		ID:   int64(record.ID),
+		Name: record.Name,
+		Slug: record.Slug,
+	}
+
+	if parentID, ok := record.ParentID(); ok {
+		id := int64(parentID)
+		category.ParentID = &id
+	}
+
+	return category
+}
+
+func ValidateCategory(v *validator.Validator, category *Category) {
+	v.Check(category.Name != "", "name", "must be provided")
+	v.Check(len(category.Name) <= 50, "name", "must not be more than 50 bytes")
+
+	v.Check(category.Slug != "", "slug", "must be provided")
+	v.Check(len(category.Slug) <= 50, "slug", "must not be more than 50 bytes")
+	v.Check(validator.Matches(category.Slug, validator.SlugRX), "slug", "must only contain lowercase letters, digits and dashes")
+
+	if category.ParentID != nil {
+		v.Check(*category.ParentID != category.ID, "parent_id", "must not be the category itself")
+	}
+}
+
+// BuildCategoryTree nests a flat list of categories under their parents and
+// returns the top-level categories.
+func BuildCategoryTree(categories []*Category) []*Category {
+	byID := make(map[int64]*Category, len(categories))
+	for _, category := range categories {
+		byID[category.ID] = category
+	}
+
+	roots := []*Category{}
+	for _, category := range categories {
+		if category.ParentID == nil {
+			roots = append(roots, category)
+			continue
+		}
+
+		if parent, ok := byID[*category.ParentID]; ok {
+			parent.Children = append(parent.Children, category)
+		}
+	}
+
+	return roots
+}
+
+type CategoryModel struct {
+	DB *db.PrismaClient
+}
+
+// categoryTreeSQL returns a query selecting the id of the category given by
+// the placeholder and of every category below it in the tree.
+func categoryTreeSQL(placeholder string) string {
+	return `
+		WITH RECURSIVE tree AS (
+			SELECT "id" FROM "Category" WHERE "id" = ` + placeholder + `::int
+			UNION ALL
+			SELECT c."id" FROM "Category" c JOIN tree t ON c."parentId" = t."id"
+		)
+		SELECT "id" FROM tree`
+}
+
+// descendantIDs returns the id of the category and of every category below
+// it in the tree.
+func descendantIDs(ctx context.Context, client *db.PrismaClient, categoryID int64) ([]int, error) {
+	var rows []struct {
+		ID int `json:"id"`
+	}
+
+	err := client.Prisma.QueryRaw(categoryTreeSQL("$1"), int(categoryID)).Exec(ctx, &rows)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+
+	return ids, nil
+}
+
+func (m CategoryModel) Insert(category *Category) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	params := []db.CategorySetParam{}
+	if category.ParentID != nil {
+		params = append(params, db.Category.Parent.Link(
+			db.Category.ID.Equals(int(*category.ParentID)),
+		))
+	}
+
+	record, err := m.DB.Category.CreateOne(
+		db.Category.Name.Set(category.Name),
+		db.Category.Slug.Set(category.Slug),
+		params...,
+	).Exec(ctx)
+
+	if err != nil {
+		if _, isErr := db.IsErrUniqueConstraint(err); isErr {
+			return ErrDuplicateSlug
+		}
+		return err
+	}
+
+	category.ID = int64(record.ID)
+
+	return nil
+}
+
+func (m CategoryModel) Get(categoryID int64) (*Category, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	record, err := m.DB.Category.FindUnique(
+		db.Category.ID.Equals(int(categoryID)),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return newCategory(record), nil
+}
+
+func (m CategoryModel) GetBySlug(slug string) (*Category, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	record, err := m.DB.Category.FindUnique(
+		db.Category.Slug.Equals(slug),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return newCategory(record), nil
+}
+
+func (m CategoryModel) GetAll() ([]*Category, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	records, err := m.DB.Category.FindMany().OrderBy(
+		db.Category.Name.Order(db.SortOrderAsc),
+	).Exec(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	categories := []*Category{}
+	for _, record := range records {
+		categories = append(categories, newCategory(&record))
+	}
+
+	return categories, nil
+}
+
+// Update saves the category. Moving a category under one of its own
+// descendants would cut the branch off the tree, so it is rejected with
+// ErrCategoryCycle.
+func (m CategoryModel) Update(category *Category) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	params := []db.CategorySetParam{
+		db.Category.Name.Set(category.Name),
+		db.Category.Slug.Set(category.Slug),
+	}
+
+	if category.ParentID != nil {
+		ids, err := descendantIDs(ctx, m.DB, category.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			if int64(id) == *category.ParentID {
+				return ErrCategoryCycle
+			}
+		}
+
+		params = append(params, db.Category.Parent.Link(
+			db.Category.ID.Equals(int(*category.ParentID)),
+		))
+	} else {
+		params = append(params, db.Category.Parent.Unlink())
+	}
+
+	_, err := m.DB.Category.FindUnique(
+		db.Category.ID.Equals(int(category.ID)),
+	).Update(params...).Exec(ctx)
+
+	if err != nil {
+		if _, isErr := db.IsErrUniqueConstraint(err); isErr {
+			return ErrDuplicateSlug
+		}
+
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes a category that has no subcategories. Its products are left
+// without a category.
+func (m CategoryModel) Delete(categoryID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.Category.FindFirst(
+		db.Category.ParentID.Equals(int(categoryID)),
+	).Exec(ctx)
+
+	switch {
+	case err == nil:
+		return ErrCategoryHasChildren
+	case !errors.Is(err, db.ErrNotFound):
+		return err
+	}
+
+	_, err = m.DB.Category.FindUnique(
+		db.Category.ID.Equals(int(categoryID)),
+	).Delete().Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,279 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/steebchen/prisma-client-go/runtime/transaction"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/prisma/db"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/validator"
+)
+
+// The kinds of coupon. Percent and fixed coupons take money off the items
+// they apply to; free shipping coupons waive the order's shipping charge.
+const (
+	CouponKindPercent      = "percent"
+	CouponKindFixed        = "fixed"
+	CouponKindFreeShipping = "free_shipping"
+)
+
+var CouponKinds = []string{
+	CouponKindPercent,
+	CouponKindFixed,
+	CouponKindFreeShipping,
+}
+
+// couponUsageConstraint and couponUseConstraint are the names of the check
+// constraints that keep a coupon from being used more times than its global
+// and per-user limits allow. Both counters are bumped in the transaction that
+// places the order, so concurrent checkouts cannot overshoot either limit.
+const (
+	couponUsageConstraint = "Coupon_usage_check"
+	couponUseConstraint   = "CouponUse_limit_check"
+)
+
+func isCouponUsageViolation(err error) bool {
+	return err != nil && (strings.Contains(err.Error(), couponUsageConstraint) || strings.Contains(err.Error(), couponUseConstraint))
+}
+
+// releasedCouponStatuses are the statuses in which an order no longer counts
+// as a use of its coupon, towards either the global or the per-user limit.
+var releasedCouponStatuses = []string{OrderStatusCancelled, OrderStatusPaymentFailed}
+
+// releasesCoupon reports whether moving an order between the two statuses
+// hands its coupon use back.
+func releasesCoupon(from, to string) bool {
+	return !validator.In(from, releasedCouponStatuses...) && validator.In(to, releasedCouponStatuses...)
+}
+
+type Coupon struct {
+	ID             int64      `json:"id"`
+	Code           string     `json:"code"`
+	Kind           string     `json:"kind"`
+	Value          int        `json:"value"`
+	MinOrder       int        `json:"min_order"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	MaxUses        *int       `json:"max_uses,omitempty"`
+	MaxUsesPerUser *int       `json:"max_uses_per_user,omitempty"`
+	TimesUsed      int        `json:"times_used"`
+	ProductIDs     []int      `json:"product_ids"`
+	CategoryIDs    []int      `json:"category_ids"`
+}
+
+// NormalizeCouponCode makes coupon codes case-insensitive.
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func ValidateCouponCode(v *validator.Validator, code string) {
+	v.Check(code != "", "code", "must be provided")
+	v.Check(validator.Matches(code, validator.CouponCodeRX), "code", "must be 3 to 20 letters or digits")
+}
+
+func ValidateCoupon(v *validator.Validator, coupon *Coupon) {
+	ValidateCouponCode(v, coupon.Code)
+
+	v.Check(validator.In(coupon.Kind, CouponKinds...), "kind", "invalid coupon kind")
+
+	switch coupon.Kind {
+	case CouponKindPercent:
+		v.Check(coupon.Value > 0 && coupon.Value <= 100, "value", "must be a percentage between 1 and 100")
+	case CouponKindFixed:
+		v.Check(coupon.Value > 0, "value", "must be a positive number")
+	case CouponKindFreeShipping:
+		v.Check(coupon.Value == 0, "value", "must not be set for free shipping coupons")
+	}
+
+	v.Check(coupon.MinOrder >= 0, "min_order", "must not be negative")
+
+	if coupon.ExpiresAt != nil {
+		v.Check(coupon.ExpiresAt.After(time.Now()), "expires_at", "must be in the future")
+	}
+
+	if coupon.MaxUses != nil {
+		v.Check(*coupon.MaxUses > 0, "max_uses", "must be a positive number")
+	}
+
+	if coupon.MaxUsesPerUser != nil {
+		v.Check(*coupon.MaxUsesPerUser > 0, "max_uses_per_user", "must be a positive number")
+	}
+}
+
+type CouponModel struct {
+	DB *db.PrismaClient
+}
+
+func (m CouponModel) Insert(coupon *Coupon) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	record, err := m.DB.Coupon.CreateOne(
+		db.Coupon.Code.Set(coupon.Code),
+		db.Coupon.Kind.Set(coupon.Kind),
+		db.Coupon.Value.Set(coupon.Value),
+		db.Coupon.MinOrder.Set(coupon.MinOrder),
+		db.Coupon.ExpiresAt.SetIfPresent(coupon.ExpiresAt),
+		db.Coupon.MaxUses.SetIfPresent(coupon.MaxUses),
+		db.Coupon.MaxUsesPerUser.SetIfPresent(coupon.MaxUsesPerUser),
+		db.Coupon.ProductIds.Set(coupon.ProductIDs),
+		db.Coupon.CategoryIds.Set(coupon.CategoryIDs),
+	).Exec(ctx)
+
+	if err != nil {
+		if _, isErr := db.IsErrUniqueConstraint(err); isErr {
+			return ErrDuplicateCouponCode
+		}
+		return err
+	}
+
+	coupon.ID = int64(record.ID)
+
+	return nil
+}
+
+// eligibleTotal adds up the lines the coupon applies to. A coupon without
+// product or category restrictions applies to every line. A category
+// restriction covers its subcategories too.
+func (m CouponModel) eligibleTotal(ctx context.Context, coupon *db.CouponModel, lines []orderLine) (decimal.Decimal, error) {
+	if len(coupon.ProductIds) == 0 && len(coupon.CategoryIds) == 0 {
+		return orderTotal(lines), nil
+	}
+
+	categories := map[int]bool{}
+	for _, categoryID := range coupon.CategoryIds {
+		ids, err := descendantIDs(ctx, m.DB, int64(categoryID))
+		if err != nil {
+			return decimal.Zero, err
+		}
+
+		for _, id := range ids {
+			categories[id] = true
+		}
+	}
+
+	total := decimal.Zero
+	for _, line := range lines {
+		categoryID, inCategory := line.product.CategoryID()
+
+		if containsInt(coupon.ProductIds, line.product.ID) || (inCategory && categories[categoryID]) {
+			total = total.Add(line.lineTotal())
+		}
+	}
+
+	return total, nil
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// discountFor checks the coupon's rules against an order the user is about to
+// place and works out the discount it gives on the items. Prices are whole
+// units, so the discount is rounded to a whole unit too and the cart shows
+// exactly what the order will store. Free shipping coupons give no discount
+// on the items; shippingFor waives the shipping charge instead.
+func (m CouponModel) discountFor(ctx context.Context, coupon *db.CouponModel, userID int64, lines []orderLine) (decimal.Decimal, error) {
+	if expiresAt, ok := coupon.ExpiresAt(); ok && time.Now().After(expiresAt) {
+		return decimal.Zero, ErrCouponExpired
+	}
+
+	if maxUses, ok := coupon.MaxUses(); ok && coupon.TimesUsed >= maxUses {
+		return decimal.Zero, ErrCouponUsedUp
+	}
+
+	if maxUsesPerUser, ok := coupon.MaxUsesPerUser(); ok {
+		var count []struct {
+			Total int `json:"total"`
+		}
+
+		err := m.DB.Prisma.QueryRaw(
+			`SELECT coalesce(sum("uses"), 0)::int AS total FROM "CouponUse" WHERE "couponId" = $1 AND "userId" = $2`,
+			coupon.ID, int(userID),
+		).Exec(ctx, &count)
+		if err != nil {
+			return decimal.Zero, err
+		}
+
+		if len(count) > 0 && count[0].Total >= maxUsesPerUser {
+			return decimal.Zero, ErrCouponUsedUp
+		}
+	}
+
+	if orderTotal(lines).LessThan(decimal.NewFromInt(int64(coupon.MinOrder))) {
+		return decimal.Zero, ErrCouponMinOrder
+	}
+
+	eligible, err := m.eligibleTotal(ctx, coupon, lines)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	if eligible.IsZero() {
+		return decimal.Zero, ErrCouponNotEligible
+	}
+
+	value := decimal.NewFromInt(int64(coupon.Value))
+
+	switch coupon.Kind {
+	case CouponKindPercent:
+		return eligible.Mul(value).Div(decimal.NewFromInt(100)).Round(0), nil
+	case CouponKindFixed:
+		return decimal.Min(value, eligible), nil
+	case CouponKindFreeShipping:
+		return decimal.Zero, nil
+	default:
+		return decimal.Zero, ErrCouponNotEligible
+	}
+}
+
+// shippingFor returns what an order is charged for shipping, given the coupon
+// applied to it, if any. The coupon must already have passed discountFor.
+func shippingFor(coupon *db.CouponModel) decimal.Decimal {
+	if coupon != nil && coupon.Kind == CouponKindFreeShipping {
+		return decimal.Zero
+	}
+
+	return decimal.NewFromInt(ShippingFee)
+}
+
+// isCouponRuleError reports whether err means a coupon cannot be used on an
+// order, as opposed to something going wrong while checking.
+func isCouponRuleError(err error) bool {
+	return errors.Is(err, ErrCouponExpired) ||
+		errors.Is(err, ErrCouponUsedUp) ||
+		errors.Is(err, ErrCouponMinOrder) ||
+		errors.Is(err, ErrCouponNotEligible)
+}
+
+// useCoupon counts one use of the coupon by the user, towards both of its
+// limits. It runs in the transaction that places the order and fails that
+// transaction with a check violation if either limit would be exceeded.
+func useCoupon(client *db.PrismaClient, coupon *db.CouponModel, userID int64) []transaction.Param {
+	var maxUsesPerUser interface{}
+	if limit, ok := coupon.MaxUsesPerUser(); ok {
+		maxUsesPerUser = limit
+	}
+
+	countGlobal := client.Coupon.FindUnique(
+		db.Coupon.ID.Equals(coupon.ID),
+	).Update(
+		db.Coupon.TimesUsed.Increment(1),
+	).Tx()
+
+	countForUser := client.Prisma.ExecuteRaw(
+		`INSERT INTO "CouponUse" ("couponId", "userId", "uses", "maxUses") VALUES ($1::int, $2::int, 1, $3::int)
+		ON CONFLICT ("couponId", "userId") DO UPDATE SET "uses" = "CouponUse"."uses" + 1, "maxUses" = EXCLUDED."maxUses"`,
+		coupon.ID, int(userID), maxUsesPerUser,
+	).Tx()
+
+	return []transaction.Param{countGlobal, countForUser}
+}
@@ -0,0 +1,247 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/prisma/db"
+)
+
+func testOrderLine(productID, price, quantity int) orderLine {
+	return orderLine{
+		variant: &db.ProductVariantModel{
+			InnerProductVariant: db.InnerProductVariant{Price: price},
+		},
+		product: &db.ProductModel{
+			InnerProduct: db.InnerProduct{ID: productID},
+		},
+		quantity: quantity,
+	}
+}
+
+func testCoupon(kind string, value int) *db.CouponModel {
+	return &db.CouponModel{
+		InnerCoupon: db.InnerCoupon{ID: 1, Code: "TEST", Kind: kind, Value: value},
+	}
+}
+
+// The cases below have no per-user limit and no category restriction, which
+// are the only rules discountFor needs the database for.
+func TestDiscountFor(t *testing.T) {
+	expired := time.Now().Add(-time.Hour)
+	maxUses := 5
+
+	tests := []struct {
+		name    string
+		coupon  func() *db.CouponModel
+		lines   []orderLine
+		want    int64
+		wantErr error
+	}{
+		{
+			name:   "percent",
+			coupon: func() *db.CouponModel { return testCoupon(CouponKindPercent, 10) },
+			lines:  []orderLine{testOrderLine(1, 1000, 2)},
+			want:   200,
+		},
+		{
+			name:   "percent rounds up from a half",
+			coupon: func() *db.CouponModel { return testCoupon(CouponKindPercent, 15) },
+			lines:  []orderLine{testOrderLine(1, 333, 1)},
+			want:   50,
+		},
+		{
+			name:   "percent rounds down",
+			coupon: func() *db.CouponModel { return testCoupon(CouponKindPercent, 10) },
+			lines:  []orderLine{testOrderLine(1, 994, 1)},
+			want:   99,
+		},
+		{
+			name:   "whole order",
+			coupon: func() *db.CouponModel { return testCoupon(CouponKindPercent, 100) },
+			lines:  []orderLine{testOrderLine(1, 999, 3)},
+			want:   2997,
+		},
+		{
+			name:   "fixed",
+			coupon: func() *db.CouponModel { return testCoupon(CouponKindFixed, 500) },
+			lines:  []orderLine{testOrderLine(1, 1000, 2)},
+			want:   500,
+		},
+		{
+			name:   "fixed is capped at the eligible total",
+			coupon: func() *db.CouponModel { return testCoupon(CouponKindFixed, 500) },
+			lines:  []orderLine{testOrderLine(1, 300, 1)},
+			want:   300,
+		},
+		{
+			name: "product restriction",
+			coupon: func() *db.CouponModel {
+				c := testCoupon(CouponKindPercent, 10)
+				c.ProductIds = []int{2}
+				return c
+			},
+			lines: []orderLine{testOrderLine(1, 1000, 1), testOrderLine(2, 500, 2)},
+			want:  100,
+		},
+		{
+			name: "fixed on restricted products",
+			coupon: func() *db.CouponModel {
+				c := testCoupon(CouponKindFixed, 5000)
+				c.ProductIds = []int{2}
+				return c
+			},
+			lines: []orderLine{testOrderLine(1, 1000, 1), testOrderLine(2, 500, 2)},
+			want:  1000,
+		},
+		{
+			name: "no product matches",
+			coupon: func() *db.CouponModel {
+				c := testCoupon(CouponKindPercent, 10)
+				c.ProductIds = []int{3}
+				return c
+			},
+			lines:   []orderLine{testOrderLine(1, 1000, 1)},
+			wantErr: ErrCouponNotEligible,
+		},
+		{
+			name: "below the minimum order",
+			coupon: func() *db.CouponModel {
+				c := testCoupon(CouponKindFixed, 100)
+				c.MinOrder = 2001
+				return c
+			},
+			lines:   []orderLine{testOrderLine(1, 1000, 2)},
+			wantErr: ErrCouponMinOrder,
+		},
+		{
+			name: "at the minimum order",
+			coupon: func() *db.CouponModel {
+				c := testCoupon(CouponKindFixed, 100)
+				c.MinOrder = 2000
+				return c
+			},
+			lines: []orderLine{testOrderLine(1, 1000, 2)},
+			want:  100,
+		},
+		{
+			name: "expired",
+			coupon: func() *db.CouponModel {
+				c := testCoupon(CouponKindPercent, 10)
+				c.InnerCoupon.ExpiresAt = &expired
+				return c
+			},
+			lines:   []orderLine{testOrderLine(1, 1000, 1)},
+			wantErr: ErrCouponExpired,
+		},
+		{
+			name: "used up",
+			coupon: func() *db.CouponModel {
+				c := testCoupon(CouponKindPercent, 10)
+				c.InnerCoupon.MaxUses = &maxUses
+				c.TimesUsed = maxUses
+				return c
+			},
+			lines:   []orderLine{testOrderLine(1, 1000, 1)},
+			wantErr: ErrCouponUsedUp,
+		},
+		{
+			name:   "free shipping takes nothing off the items",
+			coupon: func() *db.CouponModel { return testCoupon(CouponKindFreeShipping, 0) },
+			lines:  []orderLine{testOrderLine(1, 1000, 1)},
+			want:   0,
+		},
+		{
+			name: "free shipping on restricted products",
+			coupon: func() *db.CouponModel {
+				c := testCoupon(CouponKindFreeShipping, 0)
+				c.ProductIds = []int{3}
+				return c
+			},
+			lines:   []orderLine{testOrderLine(1, 1000, 1)},
+			wantErr: ErrCouponNotEligible,
+		},
+		{
+			name:    "unknown kind",
+			coupon:  func() *db.CouponModel { return testCoupon("buy_one_get_one", 0) },
+			lines:   []orderLine{testOrderLine(1, 1000, 1)},
+			wantErr: ErrCouponNotEligible,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CouponModel{}.discountFor(context.Background(), tt.coupon(), 1, tt.lines)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v; want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if !got.Equal(decimal.NewFromInt(tt.want)) {
+				t.Errorf("got discount %s; want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShippingFor(t *testing.T) {
+	fee := decimal.NewFromInt(ShippingFee)
+
+	tests := []struct {
+		name   string
+		coupon *db.CouponModel
+		want   decimal.Decimal
+	}{
+		{name: "no coupon", want: fee},
+		{name: "percent", coupon: testCoupon(CouponKindPercent, 10), want: fee},
+		{name: "fixed", coupon: testCoupon(CouponKindFixed, 100), want: fee},
+		{name: "free shipping", coupon: testCoupon(CouponKindFreeShipping, 0), want: decimal.Zero},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shippingFor(tt.coupon); !got.Equal(tt.want) {
+				t.Errorf("got %s; want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEligibleTotalWithoutRestrictions(t *testing.T) {
+	lines := []orderLine{testOrderLine(1, 1000, 1), testOrderLine(2, 250, 3)}
+
+	got, err := CouponModel{}.eligibleTotal(context.Background(), testCoupon(CouponKindFixed, 100), lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !got.Equal(decimal.NewFromInt(1750)) {
+		t.Errorf("got %s; want 1750", got)
+	}
+}
+
+func TestReleasesCoupon(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{from: OrderStatusPendingPayment, to: OrderStatusCancelled, want: true},
+		{from: OrderStatusPendingPayment, to: OrderStatusPaymentFailed, want: true},
+		{from: OrderStatusPaid, to: OrderStatusCancelled, want: true},
+		{from: OrderStatusPendingPayment, to: OrderStatusPaid},
+		{from: OrderStatusPaid, to: OrderStatusRefunded},
+		{from: OrderStatusCancelled, to: OrderStatusCancelled},
+		{from: OrderStatusPaymentFailed, to: OrderStatusCancelled},
+	}
+
+	for _, tt := range tests {
+		if got := releasesCoupon(tt.from, tt.to); got != tt.want {
+			t.Errorf("releasesCoupon(%q, %q) = %t; want %t", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package data
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/steebchen/prisma-client-go/runtime/transaction"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/prisma/db"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/validator"
+)
+
+const (
+	StockReasonRestock     = "restock"
+	StockReasonCorrection  = "correction"
+	StockReasonDamaged     = "damaged"
+	StockReasonLost        = "lost"
+	StockReasonReturned    = "returned"
+	StockReasonReservation = "reservation"
+	StockReasonRelease     = "release"
+	StockReasonSale        = "sale"
+)
+
+// AdminStockReasons are the reason codes an admin may give when adjusting
+// stock by hand. The other codes are only written by the order flow.
+var AdminStockReasons = []string{
+	StockReasonRestock,
+	StockReasonCorrection,
+	StockReasonDamaged,
+	StockReasonLost,
+	StockReasonReturned,
+}
+
+// stockCheckConstraint is the name of the check constraint that keeps the
+// on-hand and reserved quantities of a variant from going negative and keeps
+// reservations within what is on hand.
+const stockCheckConstraint = "ProductVariant_stock_check"
+
+func isStockCheckViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), stockCheckConstraint)
+}
+
+func ValidateStockAdjustment(v *validator.Validator, delta int, reason, note string) {
+	v.Check(delta != 0, "delta", "must not be zero")
+	v.Check(validator.In(reason, AdminStockReasons...), "reason", "invalid reason code")
+	v.Check(len(note) <= 500, "note", "must not be more than 500 bytes")
+}
+
+type StockMovement struct {
+	ID            int64     `json:"id"`
+	VariantID     int64     `json:"variant_id"`
+	OnHandDelta   int       `json:"on_hand_delta"`
+	ReservedDelta int       `json:"reserved_delta"`
+	Reason        string    `json:"reason"`
+	Note          *string   `json:"note,omitempty"`
+	OrderID       *int64    `json:"order_id,omitempty"`
+	ActorID       *int64    `json:"actor_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func newStockMovement(record *db.StockMovementModel) *StockMovement {
+	movement := &StockMovement{
+		ID:            int64(record.ID),
+		VariantID:     int64(record.VariantID),
+		OnHandDelta:   record.OnHandDelta,
+		ReservedDelta: record.ReservedDelta,
+		Reason:        record.Reason,
+		CreatedAt:     record.CreatedAt,
+	}
+
+	if note, ok := record.Note(); ok {
+		movement.Note = &note
+	}
+
+	if orderID, ok := record.OrderID(); ok {
+		id := int64(orderID)
+		movement.OrderID = &id
+	}
+
+	if actorID, ok := record.ActorID(); ok {
+		id := int64(actorID)
+		movement.ActorID = &id
+	}
+
+	return movement
+}
+
+// reserveStock returns the operations that set aside the units of a line for
+// the order with the given reference. They fail on the stock check
+// constraint if another order took the last units first.
+func reserveStock(client *db.PrismaClient, line orderLine, reference string, userID int64) []transaction.Param {
+	reserve := client.ProductVariant.FindUnique(
+		db.ProductVariant.ID.Equals(line.variant.ID),
+	).Update(
+		db.ProductVariant.Reserved.Increment(line.quantity),
+	).Tx()
+
+	record := client.StockMovement.CreateOne(
+		db.StockMovement.Variant.Link(
+			db.ProductVariant.ID.Equals(line.variant.ID),
+		),
+		db.StockMovement.Reason.Set(StockReasonReservation),
+		db.StockMovement.ReservedDelta.Set(line.quantity),
+		db.StockMovement.Order.Link(
+			db.Orders.Reference.Equals(reference),
+		),
+		db.StockMovement.Actor.Link(
+			db.User.ID.Equals(int(userID)),
+		),
+	).Tx()
+
+	return []transaction.Param{reserve, record}
+}
+
+// stockEffect describes what a status transition does to the stock of the
+// order's variants. A sale takes the reserved units off the shelf, a release
+// hands them back.
+type stockEffect struct {
+	onHandFactor int
+	reason       string
+}
+
+func stockEffectOf(from, to string) *stockEffect {
+	reserved := validator.In(from, OrderStatusPendingPayment, OrderStatusPaid, OrderStatusPacked)
+
+	switch {
+	case to == OrderStatusShipped:
+		return &stockEffect{onHandFactor: 1, reason: StockReasonSale}
+	case reserved && validator.In(to, OrderStatusCancelled, OrderStatusRefunded, OrderStatusPaymentFailed):
+		return &stockEffect{onHandFactor: 0, reason: StockReasonRelease}
+	default:
+		return nil
+	}
+}
+
+// AdjustStock changes the on-hand quantity of a variant by delta and records
+// the change in the stock ledger. Archived variants cannot be sold, so their
+// stock is not adjusted and ErrRecordNotFound is returned, as Get does.
+func (m VariantModel) AdjustStock(variantID int64, delta int, reason, note string, actorID int64) (*Variant, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var noteParam interface{}
+	if note != "" {
+		noteParam = note
+	}
+
+	adjust := m.DB.ProductVariant.FindMany(
+		db.ProductVariant.ID.Equals(int(variantID)),
+		db.ProductVariant.ArchivedAt.IsNull(),
+	).Update(
+		db.ProductVariant.OnHand.Increment(delta),
+	).Tx()
+
+	// The movement is only recorded if the variant was adjusted, so a variant
+	// archived in the meantime gets no ledger entry either.
+	record := m.DB.Prisma.ExecuteRaw(
+		`INSERT INTO "StockMovement" ("variantId", "onHandDelta", "reason", "note", "actorId")
+		SELECT "id", $2::int, $3::text, $4::text, $5::int FROM "ProductVariant" WHERE "id" = $1::int AND "archivedAt" IS NULL`,
+		int(variantID), delta, reason, noteParam, int(actorID),
+	).Tx()
+
+	err := m.DB.Prisma.Transaction(adjust, record).Exec(ctx)
+	if err != nil {
+		switch {
+		case isStockCheckViolation(err):
+			return nil, ErrInsufficientStock
+		default:
+			return nil, err
+		}
+	}
+
+	if adjust.Result().Count == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	return m.Get(variantID)
+}
+
+func (m VariantModel) GetStockMovements(variantID int64, filters Filters) ([]*StockMovement, Metadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var count []struct {
+		Total int `json:"total"`
+	}
+
+	err := m.DB.Prisma.QueryRaw(
+		`SELECT count(*)::int AS total FROM "StockMovement" WHERE "variantId" = $1`, int(variantID),
+	).Exec(ctx, &count)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	records, err := m.DB.StockMovement.FindMany(
+		db.StockMovement.VariantID.Equals(int(variantID)),
+	).Take(filters.limit()).Skip(filters.offset()).OrderBy(
+		db.StockMovement.ID.Order(filters.sortDirection()),
+	).Exec(ctx)
+
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	movements := []*StockMovement{}
+	for _, record := range records {
+		movements = append(movements, newStockMovement(&record))
+	}
+
+	var totalRecords int
+	if len(count) > 0 {
+		totalRecords = count[0].Total
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return movements, metadata, nil
+}
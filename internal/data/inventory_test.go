@@ -0,0 +1,45 @@
+package data
+
+import "testing"
+
+func TestStockEffectOf(t *testing.T) {
+	tests := []struct {
+		from, to   string
+		want       bool
+		wantFactor int
+		wantReason string
+	}{
+		{from: OrderStatusPacked, to: OrderStatusShipped, want: true, wantFactor: 1, wantReason: StockReasonSale},
+		{from: OrderStatusPendingPayment, to: OrderStatusCancelled, want: true, wantReason: StockReasonRelease},
+		{from: OrderStatusPendingPayment, to: OrderStatusPaymentFailed, want: true, wantReason: StockReasonRelease},
+		{from: OrderStatusPaid, to: OrderStatusCancelled, want: true, wantReason: StockReasonRelease},
+		{from: OrderStatusPaid, to: OrderStatusRefunded, want: true, wantReason: StockReasonRelease},
+		{from: OrderStatusPacked, to: OrderStatusCancelled, want: true, wantReason: StockReasonRelease},
+		{from: OrderStatusPendingPayment, to: OrderStatusPaid},
+		{from: OrderStatusPaid, to: OrderStatusPacked},
+		{from: OrderStatusShipped, to: OrderStatusDelivered},
+		// Delivered units have left the shelf; refunding them does not put
+		// them back.
+		{from: OrderStatusDelivered, to: OrderStatusRefunded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			effect := stockEffectOf(tt.from, tt.to)
+
+			if (effect != nil) != tt.want {
+				t.Fatalf("got effect %+v; want one: %t", effect, tt.want)
+			}
+			if effect == nil {
+				return
+			}
+
+			if effect.onHandFactor != tt.wantFactor {
+				t.Errorf("got on-hand factor %d; want %d", effect.onHandFactor, tt.wantFactor)
+			}
+			if effect.reason != tt.wantReason {
+				t.Errorf("got reason %q; want %q", effect.reason, tt.wantReason)
+			}
+		})
+	}
+}
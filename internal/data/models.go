@@ -7,22 +7,62 @@ import (
 )
 
 var (
-	ErrRecordNotFound   = errors.New("record not found")
-	ErrDuplicateEmail   = errors.New("error duplicate email")
-	ErrDuplicatePhoneNo = errors.New("error duplicate phone no")
-	ErrMultipleCarts    = errors.New("error user cannot have more than one cart")
+	ErrRecordNotFound        = errors.New("record not found")
+	ErrDuplicateEmail        = errors.New("error duplicate email")
+	ErrDuplicatePhoneNo      = errors.New("error duplicate phone no")
+	ErrMultipleCarts         = errors.New("error user cannot have more than one cart")
+	ErrEmptyCart             = errors.New("error cart is empty")
+	ErrInvalidAddress        = errors.New("error address does not belong to the user")
+	ErrInvalidPayment        = errors.New("error payment method does not exist")
+	ErrInvalidTransition     = errors.New("error order cannot move to the requested status")
+	ErrEditConflict          = errors.New("edit conflict")
+	ErrNotCancellable        = errors.New("error order can no longer be cancelled")
+	ErrNoDefaultAddress      = errors.New("error user has no default address")
+	ErrInsufficientStock     = errors.New("error not enough stock")
+	ErrDuplicateSlug         = errors.New("error duplicate slug")
+	ErrCategoryCycle         = errors.New("error category cannot be moved under itself")
+	ErrCategoryHasChildren   = errors.New("error category has subcategories")
+	ErrDuplicateSKU          = errors.New("error duplicate sku")
+	ErrNotVerifiedBuyer      = errors.New("error user has not received the product")
+	ErrDuplicateReview       = errors.New("error user has already reviewed the product")
+	ErrDuplicateCouponCode   = errors.New("error duplicate coupon code")
+	ErrCouponExpired         = errors.New("error coupon has expired")
+	ErrCouponUsedUp          = errors.New("error coupon has reached its usage limit")
+	ErrCouponMinOrder        = errors.New("error order is below the coupon's minimum")
+	ErrCouponNotEligible     = errors.New("error coupon does not apply to any item in the order")
+	ErrDuplicatePaymentEvent = errors.New("error payment event has already been received")
+	ErrCartQuantityLimit     = errors.New("error cart item quantity is over the limit")
+	ErrRefreshTokenReused    = errors.New("error refresh token has already been used")
 )
 
 type Models struct {
-	Users    UserModel
-	Products ProductModel
-	Carts    CartModel
+	Users           UserModel
+	Products        ProductModel
+	Carts           CartModel
+	Orders          OrderModel
+	Addresses       AddressModel
+	Tokens          TokenModel
+	Categories      CategoryModel
+	Variants        VariantModel
+	Reviews         ReviewModel
+	Coupons         CouponModel
+	PaymentAttempts PaymentAttemptModel
+	PaymentEvents   PaymentEventModel
 }
 
 func NewModels(db *db.PrismaClient) Models {
 	return Models{
-		Users:    UserModel{DB: db},
-		Products: ProductModel{DB: db},
-		Carts:    CartModel{DB: db},
+		Users:           UserModel{DB: db},
+		Products:        ProductModel{DB: db},
+		Carts:           CartModel{DB: db},
+		Orders:          OrderModel{DB: db},
+		Addresses:       AddressModel{DB: db},
+		Tokens:          TokenModel{DB: db},
+		Categories:      CategoryModel{DB: db},
+		Variants:        VariantModel{DB: db},
+		Reviews:         ReviewModel{DB: db},
+		Coupons:         CouponModel{DB: db},
+		PaymentAttempts: PaymentAttemptModel{DB: db},
+		PaymentEvents:   PaymentEventModel{DB: db},
 	}
 }
@@ -0,0 +1,45 @@
+package data
+
+import (
+	"github.com/vaidik-bajpai/ecommerce-api/internal/prisma/db"
+)
+
+// OrderItem is a line of an order. The name, SKU and unit price are copied
+// from the variant when the order is placed, so editing or deleting the
+// variant later does not change what the order says was bought.
+type OrderItem struct {
+	ID        int64   `json:"id"`
+	ProductID *int64  `json:"product_id"`
+	VariantID *int64  `json:"variant_id"`
+	SKU       *string `json:"sku"`
+	Name      string  `json:"name"`
+	Quantity  int     `json:"quantity"`
+	UnitPrice uint64  `json:"unit_price"`
+	LineTotal uint64  `json:"line_total"`
+}
+
+func newOrderItem(record *db.OrderItemModel) OrderItem {
+	item := OrderItem{
+		ID:        int64(record.ID),
+		Name:      record.Name,
+		Quantity:  record.Quantity,
+		UnitPrice: uint64(record.UnitPrice),
+		LineTotal: uint64(record.LineTotal),
+	}
+
+	if productID, ok := record.ProductID(); ok {
+		id := int64(productID)
+		item.ProductID = &id
+	}
+
+	if variantID, ok := record.VariantID(); ok {
+		id := int64(variantID)
+		item.VariantID = &id
+	}
+
+	if sku, ok := record.Sku(); ok {
+		item.SKU = &sku
+	}
+
+	return item
+}
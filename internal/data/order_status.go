@@ -0,0 +1,258 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/vaidik-bajpai/ecommerce-api/internal/prisma/db"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/validator"
+)
+
+const (
+	OrderStatusPendingPayment = "pending_payment"
+	OrderStatusPaymentFailed  = "payment_failed"
+	OrderStatusPaid           = "paid"
+	OrderStatusPacked         = "packed"
+	OrderStatusShipped        = "shipped"
+	OrderStatusDelivered      = "delivered"
+	OrderStatusCancelled      = "cancelled"
+	OrderStatusRefunded       = "refunded"
+)
+
+var OrderStatuses = []string{
+	OrderStatusPendingPayment,
+	OrderStatusPaymentFailed,
+	OrderStatusPaid,
+	OrderStatusPacked,
+	OrderStatusShipped,
+	OrderStatusDelivered,
+	OrderStatusCancelled,
+	OrderStatusRefunded,
+}
+
+// orderTransitions lists the statuses an order may move to from each status.
+// Cancelled, refunded and failed orders are final.
+var orderTransitions = map[string][]string{
+	OrderStatusPendingPayment: {OrderStatusPaid, OrderStatusPaymentFailed, OrderStatusCancelled},
+	OrderStatusPaid:           {OrderStatusPacked, OrderStatusCancelled, OrderStatusRefunded},
+	OrderStatusPacked:         {OrderStatusShipped, OrderStatusCancelled},
+	OrderStatusShipped:        {OrderStatusDelivered},
+	OrderStatusDelivered:      {OrderStatusRefunded},
+}
+
+// customerCancellableStatuses are the statuses in which a customer may still
+// cancel their own order. Later statuses need an admin.
+var customerCancellableStatuses = []string{
+	OrderStatusPendingPayment,
+	OrderStatusPaid,
+}
+
+func CanTransition(from, to string) bool {
+	return validator.In(to, orderTransitions[from]...)
+}
+
+func ValidateOrderStatus(v *validator.Validator, status string) {
+	v.Check(status != "", "status", "must be provided")
+	v.Check(validator.In(status, OrderStatuses...), "status", "invalid status value")
+}
+
+type OrderStatusChange struct {
+	ID         int64     `json:"id"`
+	FromStatus *string   `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ActorID    *int64    `json:"actor_id"`
+	Note       *string   `json:"note,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func newOrderStatusChange(record *db.OrderStatusHistoryModel) OrderStatusChange {
+	change := OrderStatusChange{
+		ID:        int64(record.ID),
+		ToStatus:  record.ToStatus,
+		CreatedAt: record.CreatedAt,
+	}
+
+	if fromStatus, ok := record.FromStatus(); ok {
+		change.FromStatus = &fromStatus
+	}
+
+	if actorID, ok := record.ActorID(); ok {
+		id := int64(actorID)
+		change.ActorID = &id
+	}
+
+	if note, ok := record.Note(); ok {
+		change.Note = &note
+	}
+
+	return change
+}
+
+// Get returns an order with its items and status history, regardless of who
+// placed it. Handlers serving customers must use GetForUser instead.
+func (m OrderModel) Get(orderID int64) (*Order, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	record, err := m.DB.Orders.FindUnique(
+		db.Orders.ID.Equals(int(orderID)),
+	).With(
+		db.Orders.Items.Fetch().OrderBy(
+			db.OrderItem.ID.Order(db.SortOrderAsc),
+		),
+		db.Orders.StatusHistory.Fetch().OrderBy(
+			db.OrderStatusHistory.ID.Order(db.SortOrderAsc),
+		),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	order, err := newOrder(record)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range record.Items() {
+		order.Items = append(order.Items, newOrderItem(&item))
+	}
+
+	for _, change := range record.StatusHistory() {
+		order.History = append(order.History, newOrderStatusChange(&change))
+	}
+
+	return order, nil
+}
+
+// GetByReference returns an order by the reference it is known by outside
+// the store, such as at the payment provider.
+func (m OrderModel) GetByReference(reference string) (*Order, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	record, err := m.DB.Orders.FindUnique(
+		db.Orders.Reference.Equals(reference),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return m.Get(int64(record.ID))
+}
+
+// Transition moves an order to the given status, records the change in the
+// status history and applies its effect on stock and on the use of its
+// coupon, all in one statement. The update only applies if the order is still
+// in the status it was read in, so two concurrent transitions cannot both
+// succeed.
+func (m OrderModel) Transition(orderID int64, to string, actorID *int64, note string) (*Order, error) {
+	order, err := m.Get(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !CanTransition(order.Status, to) {
+		return nil, ErrInvalidTransition
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var actor interface{}
+	if actorID != nil {
+		actor = int(*actorID)
+	}
+
+	query := `
+		WITH updated AS (
+			UPDATE "Orders" SET "status" = $3::text
+			WHERE "id" = $1::int AND "status" = $2::text
+			RETURNING "id"
+		), history AS (
+			INSERT INTO "OrderStatusHistory" ("orderId", "fromStatus", "toStatus", "actorId", "note")
+			SELECT "id", $2::text, $3::text, $4::int, NULLIF($5::text, '') FROM updated
+		)`
+
+	params := []interface{}{int(orderID), order.Status, to, actor, note}
+
+	if effect := stockEffectOf(order.Status, to); effect != nil {
+		query += `, lines AS (
+			SELECT i."variantId", sum(i."quantity")::int AS "quantity"
+			FROM "OrderItem" i JOIN updated u ON i."orderId" = u."id"
+			WHERE i."variantId" IS NOT NULL
+			GROUP BY i."variantId"
+		), stock AS (
+			UPDATE "ProductVariant" v
+			SET "onHand" = v."onHand" - $6::int * l."quantity", "reserved" = v."reserved" - l."quantity"
+			FROM lines l WHERE v."id" = l."variantId"
+		), ledger AS (
+			INSERT INTO "StockMovement" ("variantId", "onHandDelta", "reservedDelta", "reason", "orderId", "actorId")
+			SELECT l."variantId", -$6::int * l."quantity", -l."quantity", $7::text, $1::int, $4::int FROM lines l
+		)`
+
+		params = append(params, effect.onHandFactor, effect.reason)
+	}
+
+	if releasesCoupon(order.Status, to) {
+		query += `, coupon AS (
+			UPDATE "Coupon" c SET "timesUsed" = c."timesUsed" - 1
+			FROM "Orders" o JOIN updated u ON o."id" = u."id"
+			WHERE c."id" = o."couponId" AND c."timesUsed" > 0
+		), coupon_use AS (
+			UPDATE "CouponUse" cu SET "uses" = cu."uses" - 1
+			FROM "Orders" o JOIN updated u ON o."id" = u."id"
+			WHERE cu."couponId" = o."couponId" AND cu."userId" = o."userId" AND cu."uses" > 0
+		)`
+	}
+
+	query += `
+		SELECT count(*)::int AS "updated" FROM updated`
+
+	var result []struct {
+		Updated int `json:"updated"`
+	}
+
+	err = m.DB.Prisma.QueryRaw(query, params...).Exec(ctx, &result)
+	if err != nil {
+		switch {
+		case isStockCheckViolation(err):
+			return nil, ErrInsufficientStock
+		default:
+			return nil, err
+		}
+	}
+
+	if len(result) == 0 || result[0].Updated == 0 {
+		return nil, ErrEditConflict
+	}
+
+	return m.Get(orderID)
+}
+
+// Cancel cancels one of the user's own orders. Orders belonging to someone
+// else are reported as not found.
+func (m OrderModel) Cancel(orderID, userID int64, reason string) (*Order, error) {
+	order, err := m.GetForUser(orderID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !validator.In(order.Status, customerCancellableStatuses...) {
+		return nil, ErrNotCancellable
+	}
+
+	return m.Transition(orderID, OrderStatusCancelled, &userID, reason)
+}
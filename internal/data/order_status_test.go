@@ -0,0 +1,50 @@
+package data
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	allowed := map[[2]string]bool{
+		{OrderStatusPendingPayment, OrderStatusPaid}:          true,
+		{OrderStatusPendingPayment, OrderStatusPaymentFailed}: true,
+		{OrderStatusPendingPayment, OrderStatusCancelled}:     true,
+		{OrderStatusPaid, OrderStatusPacked}:                  true,
+		{OrderStatusPaid, OrderStatusCancelled}:               true,
+		{OrderStatusPaid, OrderStatusRefunded}:                true,
+		{OrderStatusPacked, OrderStatusShipped}:               true,
+		{OrderStatusPacked, OrderStatusCancelled}:             true,
+		{OrderStatusShipped, OrderStatusDelivered}:            true,
+		{OrderStatusDelivered, OrderStatusRefunded}:           true,
+	}
+
+	// Every pair of statuses is checked, so a transition added to the table
+	// by mistake fails the test as well as one that goes missing.
+	for _, from := range OrderStatuses {
+		for _, to := range OrderStatuses {
+			want := allowed[[2]string{from, to}]
+
+			if got := CanTransition(from, to); got != want {
+				t.Errorf("CanTransition(%q, %q) = %t; want %t", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestCanTransitionFinalStatuses(t *testing.T) {
+	for _, from := range []string{OrderStatusCancelled, OrderStatusRefunded, OrderStatusPaymentFailed} {
+		for _, to := range OrderStatuses {
+			if CanTransition(from, to) {
+				t.Errorf("%s is final but may move to %s", from, to)
+			}
+		}
+	}
+}
+
+func TestCanTransitionUnknownStatus(t *testing.T) {
+	if CanTransition("lost", OrderStatusPaid) {
+		t.Error("an unknown status may move to paid")
+	}
+
+	if CanTransition(OrderStatusPendingPayment, "lost") {
+		t.Error("pending_payment may move to an unknown status")
+	}
+}
@@ -0,0 +1,423 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/steebchen/prisma-client-go/runtime/transaction"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/prisma/db"
+)
+
+// ShippingFee is what every order is charged for delivery, in the same whole
+// units as prices.
+const ShippingFee = 50
+
+type Order struct {
+	ID        int64               `json:"id"`
+	Reference string              `json:"reference"`
+	OrderedAt time.Time           `json:"ordered_at"`
+	Status    string              `json:"status"`
+	Price     uint64              `json:"price"`
+	Shipping  uint64              `json:"shipping"`
+	Discount  uint64              `json:"discount"`
+	Total     uint64              `json:"total"`
+	CouponID  *int64              `json:"coupon_id,omitempty"`
+	PaymentID int64               `json:"payment_id"`
+	AddressID int64               `json:"address_id"`
+	UserID    int64               `json:"user_id"`
+	Items     []OrderItem         `json:"items,omitempty"`
+	History   []OrderStatusChange `json:"history,omitempty"`
+}
+
+// newOrderReference generates the unique reference of an order up front, so
+// the order items created in the same transaction can link to the order
+// before its id is known.
+func newOrderReference() (string, error) {
+	randomBytes := make([]byte, 16)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(randomBytes), nil
+}
+
+func newOrder(record *db.OrdersModel) (*Order, error) {
+	orderedAt, ok := record.OrderedAt()
+	if !ok {
+		return nil, errors.New("error accessing ordered at")
+	}
+
+	order := Order{
+		ID:        int64(record.ID),
+		Reference: record.Reference,
+		OrderedAt: orderedAt,
+		Status:    record.Status,
+		Price:     uint64(record.Price),
+		Shipping:  uint64(record.Shipping),
+		Discount:  uint64(record.Discound),
+		PaymentID: int64(record.PaymentMethod),
+		AddressID: int64(record.AddressID),
+		UserID:    int64(record.UserID),
+	}
+
+	order.Total = order.Price + order.Shipping - order.Discount
+
+	if couponID, ok := record.CouponID(); ok {
+		id := int64(couponID)
+		order.CouponID = &id
+	}
+
+	return &order, nil
+}
+
+// orderLine is a variant of a product and the quantity of it that is about
+// to be ordered. Checkout and instant buy both price their orders through it.
+type orderLine struct {
+	variant  *db.ProductVariantModel
+	product  *db.ProductModel
+	quantity int
+}
+
+func newOrderLine(variant *db.ProductVariantModel, quantity int) orderLine {
+	return orderLine{variant: variant, product: variant.Product(), quantity: quantity}
+}
+
+func (l orderLine) unitPrice() decimal.Decimal {
+	return decimal.NewFromInt(int64(l.variant.Price))
+}
+
+func (l orderLine) lineTotal() decimal.Decimal {
+	return l.unitPrice().Mul(decimal.NewFromInt(int64(l.quantity)))
+}
+
+func orderTotal(lines []orderLine) decimal.Decimal {
+	total := decimal.Zero
+	for _, line := range lines {
+		total = total.Add(line.lineTotal())
+	}
+
+	return total
+}
+
+// appliedCoupon is a coupon and the discount it gives on the order being
+// placed.
+type appliedCoupon struct {
+	coupon   *db.CouponModel
+	discount decimal.Decimal
+}
+
+type OrderModel struct {
+	DB *db.PrismaClient
+}
+
+// resolveAddress returns the address an order ships to: the given address if
+// it belongs to the user, or the user's default address when none is given.
+func (m OrderModel) resolveAddress(ctx context.Context, userID, addressID int64) (int64, error) {
+	params := []db.AddressWhereParam{
+		db.Address.UserID.Equals(int(userID)),
+		db.Address.ArchivedAt.IsNull(),
+	}
+
+	if addressID == 0 {
+		params = append(params, db.Address.IsDefault.Equals(true))
+	} else {
+		params = append(params, db.Address.ID.Equals(int(addressID)))
+	}
+
+	address, err := m.DB.Address.FindFirst(params...).Exec(ctx)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound) && addressID == 0:
+			return 0, ErrNoDefaultAddress
+		case errors.Is(err, db.ErrNotFound):
+			return 0, ErrInvalidAddress
+		default:
+			return 0, err
+		}
+	}
+
+	return int64(address.ID), nil
+}
+
+func (m OrderModel) checkPayment(ctx context.Context, paymentID int64) error {
+	_, err := m.DB.Payment.FindUnique(
+		db.Payment.ID.Equals(int(paymentID)),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return ErrInvalidPayment
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// place creates an order with one item per line in a single transaction,
+// reserving the stock for each line. Any extra operations, such as emptying
+// the cart, run in that same transaction after the order has been written.
+// An addressID of zero ships the order to the user's default address. Every
+// order is charged ShippingFee unless a free shipping coupon waives it. If a
+// coupon is given, its discount is stored on the order and its use counted.
+func (m OrderModel) place(ctx context.Context, userID, addressID, paymentID int64, lines []orderLine, coupon *appliedCoupon, extra ...transaction.Param) (*Order, error) {
+	addressID, err := m.resolveAddress(ctx, userID, addressID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.checkPayment(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	reference, err := newOrderReference()
+	if err != nil {
+		return nil, err
+	}
+
+	var createItems []db.OrderItemUniqueTxResult
+	var reservations []transaction.Param
+
+	for _, line := range lines {
+		if line.variant.OnHand-line.variant.Reserved < line.quantity {
+			return nil, ErrInsufficientStock
+		}
+
+		reservations = append(reservations, reserveStock(m.DB, line, reference, userID)...)
+
+		createItems = append(createItems, m.DB.OrderItem.CreateOne(
+			db.OrderItem.Order.Link(
+				db.Orders.Reference.Equals(reference),
+			),
+			db.OrderItem.Name.Set(line.product.Name),
+			db.OrderItem.Quantity.Set(line.quantity),
+			db.OrderItem.UnitPrice.Set(int(line.unitPrice().IntPart())),
+			db.OrderItem.LineTotal.Set(int(line.lineTotal().IntPart())),
+			db.OrderItem.Product.Link(
+				db.Product.ID.Equals(line.product.ID),
+			),
+			db.OrderItem.Variant.Link(
+				db.ProductVariant.ID.Equals(line.variant.ID),
+			),
+			db.OrderItem.Sku.Set(line.variant.Sku),
+		).Tx())
+	}
+
+	discount := decimal.Zero
+	shipping := shippingFor(nil)
+	orderParams := []db.OrdersSetParam{
+		db.Orders.Reference.Set(reference),
+	}
+
+	var countUse []transaction.Param
+
+	if coupon != nil {
+		discount = coupon.discount
+		shipping = shippingFor(coupon.coupon)
+
+		orderParams = append(orderParams, db.Orders.Coupon.Link(
+			db.Coupon.ID.Equals(coupon.coupon.ID),
+		))
+
+		countUse = useCoupon(m.DB, coupon.coupon, userID)
+	}
+
+	createOrder := m.DB.Orders.CreateOne(
+		db.Orders.Price.Set(int(orderTotal(lines).IntPart())),
+		db.Orders.Discound.Set(int(discount.IntPart())),
+		db.Orders.Shipping.Set(int(shipping.IntPart())),
+		db.Orders.Payment.Link(
+			db.Payment.ID.Equals(int(paymentID)),
+		),
+		db.Orders.User.Link(
+			db.User.ID.Equals(int(userID)),
+		),
+		db.Orders.Address.Link(
+			db.Address.ID.Equals(int(addressID)),
+		),
+		orderParams...,
+	).Tx()
+
+	recordStatus := m.DB.OrderStatusHistory.CreateOne(
+		db.OrderStatusHistory.Order.Link(
+			db.Orders.Reference.Equals(reference),
+		),
+		db.OrderStatusHistory.ToStatus.Set(OrderStatusPendingPayment),
+		db.OrderStatusHistory.Actor.Link(
+			db.User.ID.Equals(int(userID)),
+		),
+	).Tx()
+
+	ops := []transaction.Param{createOrder, recordStatus}
+	for _, createItem := range createItems {
+		ops = append(ops, createItem)
+	}
+	ops = append(ops, reservations...)
+	ops = append(ops, countUse...)
+	ops = append(ops, extra...)
+
+	err = m.DB.Prisma.Transaction(ops...).Exec(ctx)
+	if err != nil {
+		switch {
+		case isStockCheckViolation(err):
+			return nil, ErrInsufficientStock
+		case isCouponUsageViolation(err):
+			return nil, ErrCouponUsedUp
+		default:
+			return nil, err
+		}
+	}
+
+	order, err := newOrder(createOrder.Result())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, createItem := range createItems {
+		order.Items = append(order.Items, newOrderItem(createItem.Result()))
+	}
+
+	return order, nil
+}
+
+// InstantBuy orders quantity units of a single variant straight away,
+// leaving the user's cart untouched.
+func (m OrderModel) InstantBuy(userID, variantID int64, quantity int, addressID, paymentID int64) (*Order, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	variant, err := m.DB.ProductVariant.FindFirst(
+		db.ProductVariant.ID.Equals(int(variantID)),
+		db.ProductVariant.ArchivedAt.IsNull(),
+	).With(
+		db.ProductVariant.Product.Fetch(),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	lines := []orderLine{newOrderLine(variant, quantity)}
+
+	return m.place(ctx, userID, addressID, paymentID, lines, nil)
+}
+
+func (m OrderModel) GetForUser(orderID, userID int64) (*Order, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	record, err := m.DB.Orders.FindFirst(
+		db.Orders.ID.Equals(int(orderID)),
+		db.Orders.UserID.Equals(int(userID)),
+	).With(
+		db.Orders.Items.Fetch().OrderBy(
+			db.OrderItem.ID.Order(db.SortOrderAsc),
+		),
+		db.Orders.StatusHistory.Fetch().OrderBy(
+			db.OrderStatusHistory.ID.Order(db.SortOrderAsc),
+		),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	order, err := newOrder(record)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range record.Items() {
+		order.Items = append(order.Items, newOrderItem(&item))
+	}
+
+	for _, change := range record.StatusHistory() {
+		order.History = append(order.History, newOrderStatusChange(&change))
+	}
+
+	return order, nil
+}
+
+func (m OrderModel) GetAllForUser(userID int64, filters Filters) ([]*Order, Metadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var count []struct {
+		Total int `json:"total"`
+	}
+
+	err := m.DB.Prisma.QueryRaw(
+		`SELECT count(*)::int AS total FROM "Orders" WHERE "userId" = $1`, int(userID),
+	).Exec(ctx, &count)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	var orderBy db.OrdersOrderByParam
+
+	switch filters.sortColumn() {
+	case "price":
+		orderBy = db.Orders.Price.Order(filters.sortDirection())
+	case "ordered_at":
+		orderBy = db.Orders.OrderedAt.Order(filters.sortDirection())
+	default:
+		orderBy = db.Orders.ID.Order(filters.sortDirection())
+	}
+
+	records, err := m.DB.Orders.FindMany(
+		db.Orders.UserID.Equals(int(userID)),
+	).With(
+		db.Orders.Items.Fetch().OrderBy(
+			db.OrderItem.ID.Order(db.SortOrderAsc),
+		),
+	).Take(filters.limit()).Skip(filters.offset()).OrderBy(
+		orderBy,
+	).Exec(ctx)
+
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	orders := []*Order{}
+
+	for _, record := range records {
+		order, err := newOrder(&record)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		for _, item := range record.Items() {
+			order.Items = append(order.Items, newOrderItem(&item))
+		}
+
+		orders = append(orders, order)
+	}
+
+	var totalRecords int
+	if len(count) > 0 {
+		totalRecords = count[0].Total
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return orders, metadata, nil
+}
@@ -0,0 +1,108 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/vaidik-bajpai/ecommerce-api/internal/prisma/db"
+)
+
+const (
+	PaymentOperationAuthorize = "authorize"
+	PaymentOperationCapture   = "capture"
+	PaymentOperationVoid      = "void"
+	PaymentOperationRefund    = "refund"
+)
+
+// PaymentAttemptError is the status of an attempt that the gateway could not
+// carry out at all. Other attempts carry the status the gateway reported.
+const PaymentAttemptError = "error"
+
+// PaymentAttempt records one call to a payment gateway for an order, whether
+// or not it succeeded.
+type PaymentAttempt struct {
+	ID            int64     `json:"id"`
+	OrderID       int64     `json:"order_id"`
+	Gateway       string    `json:"gateway"`
+	Operation     string    `json:"operation"`
+	Amount        int64     `json:"amount"`
+	Status        string    `json:"status"`
+	TransactionID *string   `json:"transaction_id,omitempty"`
+	Error         *string   `json:"error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func newPaymentAttempt(record *db.PaymentAttemptModel) *PaymentAttempt {
+	attempt := &PaymentAttempt{
+		ID:        int64(record.ID),
+		OrderID:   int64(record.OrderID),
+		Gateway:   record.Gateway,
+		Operation: record.Operation,
+		Amount:    int64(record.Amount),
+		Status:    record.Status,
+		CreatedAt: record.CreatedAt,
+	}
+
+	if transactionID, ok := record.TransactionID(); ok {
+		attempt.TransactionID = &transactionID
+	}
+
+	if message, ok := record.Error(); ok {
+		attempt.Error = &message
+	}
+
+	return attempt
+}
+
+type PaymentAttemptModel struct {
+	DB *db.PrismaClient
+}
+
+func (m PaymentAttemptModel) Insert(attempt *PaymentAttempt) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	record, err := m.DB.PaymentAttempt.CreateOne(
+		db.PaymentAttempt.Order.Link(
+			db.Orders.ID.Equals(int(attempt.OrderID)),
+		),
+		db.PaymentAttempt.Gateway.Set(attempt.Gateway),
+		db.PaymentAttempt.Operation.Set(attempt.Operation),
+		db.PaymentAttempt.Amount.Set(int(attempt.Amount)),
+		db.PaymentAttempt.Status.Set(attempt.Status),
+		db.PaymentAttempt.TransactionID.SetIfPresent(attempt.TransactionID),
+		db.PaymentAttempt.Error.SetIfPresent(attempt.Error),
+	).Exec(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	attempt.ID = int64(record.ID)
+	attempt.CreatedAt = record.CreatedAt
+
+	return nil
+}
+
+// GetAllForOrder returns every attempt made for an order, oldest first.
+func (m PaymentAttemptModel) GetAllForOrder(orderID int64) ([]*PaymentAttempt, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	records, err := m.DB.PaymentAttempt.FindMany(
+		db.PaymentAttempt.OrderID.Equals(int(orderID)),
+	).OrderBy(
+		db.PaymentAttempt.ID.Order(db.SortOrderAsc),
+	).Exec(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	attempts := []*PaymentAttempt{}
+	for _, record := range records {
+		attempts = append(attempts, newPaymentAttempt(&record))
+	}
+
+	return attempts, nil
+}
@@ -0,0 +1,238 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/vaidik-bajpai/ecommerce-api/internal/prisma/db"
+)
+
+// A payment event is received before it is acted on. It ends up processed
+// once it has moved its order, unhandled when it could not be matched to
+// anything it applies to, or failed when acting on it errored. Unhandled and
+// failed events are kept so they can be replayed.
+const (
+	PaymentEventReceived  = "received"
+	PaymentEventProcessed = "processed"
+	PaymentEventUnhandled = "unhandled"
+	PaymentEventFailed    = "failed"
+)
+
+var PaymentEventStatuses = []string{
+	PaymentEventReceived,
+	PaymentEventProcessed,
+	PaymentEventUnhandled,
+	PaymentEventFailed,
+}
+
+// PaymentEvent is a webhook delivery from a payment provider, stored as it
+// was received.
+type PaymentEvent struct {
+	ID          int64           `json:"id"`
+	EventID     string          `json:"event_id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	OrderID     *int64          `json:"order_id,omitempty"`
+	Error       *string         `json:"error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	ProcessedAt *time.Time      `json:"processed_at,omitempty"`
+}
+
+func newPaymentEvent(record *db.PaymentEventModel) *PaymentEvent {
+	event := &PaymentEvent{
+		ID:        int64(record.ID),
+		EventID:   record.EventID,
+		Type:      record.Type,
+		Payload:   json.RawMessage(record.Payload),
+		Status:    record.Status,
+		CreatedAt: record.CreatedAt,
+	}
+
+	if orderID, ok := record.OrderID(); ok {
+		id := int64(orderID)
+		event.OrderID = &id
+	}
+
+	if message, ok := record.Error(); ok {
+		event.Error = &message
+	}
+
+	if processedAt, ok := record.ProcessedAt(); ok {
+		event.ProcessedAt = &processedAt
+	}
+
+	return event
+}
+
+// paymentEventAbandonedAfter is how long an event may stay received before it
+// is taken to have been dropped half way, say because the process died while
+// acting on it.
+const paymentEventAbandonedAfter = time.Minute
+
+// Replayable reports whether the event may be acted on again. A received
+// event only qualifies once it has been abandoned, so it is not acted on
+// twice while its first delivery is still being handled.
+func (e *PaymentEvent) Replayable() bool {
+	switch e.Status {
+	case PaymentEventUnhandled, PaymentEventFailed:
+		return true
+	case PaymentEventReceived:
+		return time.Since(e.CreatedAt) > paymentEventAbandonedAfter
+	default:
+		return false
+	}
+}
+
+type PaymentEventModel struct {
+	DB *db.PrismaClient
+}
+
+// Insert stores a newly received event. An event ID that has been seen
+// before is reported as ErrDuplicatePaymentEvent, which is how redeliveries
+// are told apart from new events.
+func (m PaymentEventModel) Insert(event *PaymentEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	record, err := m.DB.PaymentEvent.CreateOne(
+		db.PaymentEvent.EventID.Set(event.EventID),
+		db.PaymentEvent.Type.Set(event.Type),
+		db.PaymentEvent.Payload.Set(db.JSON(event.Payload)),
+	).Exec(ctx)
+
+	if err != nil {
+		if _, isErr := db.IsErrUniqueConstraint(err); isErr {
+			return ErrDuplicatePaymentEvent
+		}
+		return err
+	}
+
+	*event = *newPaymentEvent(record)
+
+	return nil
+}
+
+func (m PaymentEventModel) Get(id int64) (*PaymentEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	record, err := m.DB.PaymentEvent.FindUnique(
+		db.PaymentEvent.ID.Equals(int(id)),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return newPaymentEvent(record), nil
+}
+
+func (m PaymentEventModel) GetByEventID(eventID string) (*PaymentEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	record, err := m.DB.PaymentEvent.FindUnique(
+		db.PaymentEvent.EventID.Equals(eventID),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return newPaymentEvent(record), nil
+}
+
+func (m PaymentEventModel) GetAll(status string, filters Filters) ([]*PaymentEvent, Metadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var count []struct {
+		Total int `json:"total"`
+	}
+
+	err := m.DB.Prisma.QueryRaw(
+		`SELECT count(*)::int AS total FROM "PaymentEvent" WHERE "status" = $1`,
+		status,
+	).Exec(ctx, &count)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	records, err := m.DB.PaymentEvent.FindMany(
+		db.PaymentEvent.Status.Equals(status),
+	).Take(filters.limit()).Skip(filters.offset()).OrderBy(
+		db.PaymentEvent.ID.Order(filters.sortDirection()),
+	).Exec(ctx)
+
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	events := []*PaymentEvent{}
+	for _, record := range records {
+		events = append(events, newPaymentEvent(&record))
+	}
+
+	var totalRecords int
+	if len(count) > 0 {
+		totalRecords = count[0].Total
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return events, metadata, nil
+}
+
+// Resolve records what came of acting on an event: its final status, the
+// order it applied to if one was found, and why it could not be processed.
+func (m PaymentEventModel) Resolve(event *PaymentEvent, status string, orderID *int64, reason string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	params := []db.PaymentEventSetParam{
+		db.PaymentEvent.Status.Set(status),
+		db.PaymentEvent.ProcessedAt.Set(time.Now()),
+	}
+
+	if orderID != nil {
+		params = append(params, db.PaymentEvent.Order.Link(
+			db.Orders.ID.Equals(int(*orderID)),
+		))
+	}
+
+	if reason != "" {
+		params = append(params, db.PaymentEvent.Error.Set(reason))
+	} else {
+		params = append(params, db.PaymentEvent.Error.SetOptional(nil))
+	}
+
+	record, err := m.DB.PaymentEvent.FindUnique(
+		db.PaymentEvent.ID.Equals(int(event.ID)),
+	).Update(params...).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	*event = *newPaymentEvent(record)
+
+	return nil
+}
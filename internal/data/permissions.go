@@ -0,0 +1,73 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/vaidik-bajpai/ecommerce-api/internal/prisma/db"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/validator"
+)
+
+const (
+	PermissionProductsWrite = "products:write"
+	PermissionOrdersWrite   = "orders:write"
+	PermissionUsersWrite    = "users:write"
+	PermissionReviewsWrite  = "reviews:write"
+)
+
+// AdminPermissions is every permission code, which is what granting admin to
+// a user gives them.
+var AdminPermissions = Permissions{
+	PermissionProductsWrite,
+	PermissionOrdersWrite,
+	PermissionUsersWrite,
+	PermissionReviewsWrite,
+}
+
+type Permissions []string
+
+func (p Permissions) Include(code string) bool {
+	return validator.In(code, p...)
+}
+
+func ValidatePermissions(v *validator.Validator, permissions Permissions) {
+	for _, code := range permissions {
+		v.Check(AdminPermissions.Include(code), "permissions", "must only contain known permission codes")
+	}
+	v.Check(validator.Unique(permissions), "permissions", "must not contain duplicate values")
+}
+
+// SetPermissions replaces the permission set of a user.
+func (m UserModel) SetPermissions(userID int64, permissions Permissions) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.User.FindUnique(
+		db.User.ID.Equals(int(userID)),
+	).Update(
+		db.User.Permissions.Set(permissions),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GrantAdmin gives the user with the given email every permission. It is used
+// to bootstrap the first admin from the command line.
+func (m UserModel) GrantAdmin(email string) error {
+	user, err := m.GetByEmail(email)
+	if err != nil {
+		return err
+	}
+
+	return m.SetPermissions(user.ID, AdminPermissions)
+}
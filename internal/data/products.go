@@ -10,13 +10,20 @@ import (
 	"github.com/vaidik-bajpai/ecommerce-api/internal/validator"
 )
 
+// Product is an item in the catalogue. What is actually sold are its variants,
+// so its price is the lowest price among them.
 type Product struct {
-	ID        int64     `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	Name      string    `json:"name"`
-	Price     uint64    `json:"price"`
-	Rating    uint8     `json:"rating"`
-	Image     *string   `json:"image"`
+	ID          int64      `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Price       uint64     `json:"price"`
+	Rating      float64    `json:"rating"`
+	RatingCount int        `json:"rating_count"`
+	Image       *string    `json:"image"`
+	CategoryID  *int64     `json:"category_id"`
+	Variants    []*Variant `json:"variants,omitempty"`
+	Version     int        `json:"version"`
 }
 
 type ProductModel struct {
@@ -27,11 +34,19 @@ func (m ProductModel) AddProduct(product *Product) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
+	params := []db.ProductSetParam{
+		db.Product.Image.SetOptional(product.Image),
+		db.Product.Description.Set(product.Description),
+	}
+	if product.CategoryID != nil {
+		params = append(params, db.Product.Category.Link(
+			db.Category.ID.Equals(int(*product.CategoryID)),
+		))
+	}
+
 	newProduct, err := m.DB.Product.CreateOne(
 		db.Product.Name.Set(product.Name),
-		db.Product.Price.Set(int(product.Price)),
-		db.Product.Rating.Set(int(product.Rating)),
-		db.Product.Image.Set(*product.Image),
+		params...,
 	).Exec(ctx)
 	if err != nil {
 		return err
@@ -44,21 +59,49 @@ func (m ProductModel) AddProduct(product *Product) error {
 
 	product.ID = int64(newProduct.ID)
 	product.CreatedAt = createdAt
+	product.Version = newProduct.Version
 
 	return nil
 }
 
+// RemoveProduct archives a product along with its variants and takes them out
+// of any carts. Archived products are no longer listed or sold, but are kept
+// so stock ledgers, orders and reviews still refer to them.
 func (m ProductModel) RemoveProduct(productID int64) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	_, err := m.DB.Product.FindUnique(
-		db.Product.ID.Equals(int(productID)),
-	).Delete().Exec(ctx)
+	now := time.Now()
 
+	archiveProduct := m.DB.Product.FindMany(
+		db.Product.ID.Equals(int(productID)),
+		db.Product.ArchivedAt.IsNull(),
+	).Update(
+		db.Product.ArchivedAt.Set(now),
+	).Tx()
+
+	archiveVariants := m.DB.ProductVariant.FindMany(
+		db.ProductVariant.ProductID.Equals(int(productID)),
+		db.ProductVariant.ArchivedAt.IsNull(),
+	).Update(
+		db.ProductVariant.ArchivedAt.Set(now),
+	).Tx()
+
+	removeFromCarts := m.DB.CartItem.FindMany(
+		db.CartItem.Variant.Where(
+			db.ProductVariant.ProductID.Equals(int(productID)),
+		),
+	).Delete().Tx()
+
+	err := m.DB.Prisma.Transaction(archiveProduct, archiveVariants, removeFromCarts).Exec(ctx)
 	if err != nil {
 		return err
 	}
+
+	if archiveProduct.Result().Count == 0 {
+		return ErrRecordNotFound
+	}
+
 	return nil
 }
 
@@ -66,8 +109,15 @@ func (m ProductModel) Get(productID int64) (*Product, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	newProduct, err := m.DB.Product.FindUnique(
+	newProduct, err := m.DB.Product.FindFirst(
 		db.Product.ID.Equals(int(productID)),
+		db.Product.ArchivedAt.IsNull(),
+	).With(
+		db.Product.Variants.Fetch(
+			db.ProductVariant.ArchivedAt.IsNull(),
+		).OrderBy(
+			db.ProductVariant.ID.Order(db.SortOrderAsc),
+		),
 	).Exec(ctx)
 
 	if err != nil {
@@ -84,97 +134,174 @@ func (m ProductModel) Get(productID int64) (*Product, error) {
 		return nil, errors.New("error accessing created at")
 	}
 
-	image, ok := newProduct.Image()
-	if !ok {
-		return nil, errors.New("error accessing image")
+	product := Product{
+		ID:          int64(newProduct.ID),
+		Name:        newProduct.Name,
+		Description: newProduct.Description,
+		Rating:      newProduct.Rating,
+		RatingCount: newProduct.RatingCount,
+		CreatedAt:   createdAt,
+		Version:     newProduct.Version,
 	}
 
-	product := Product{
-		ID:        int64(newProduct.ID),
-		Name:      newProduct.Name,
-		Price:     uint64(newProduct.Price),
-		Rating:    uint8(newProduct.Rating),
-		CreatedAt: createdAt,
-		Image:     &image,
+	if image, ok := newProduct.Image(); ok {
+		product.Image = &image
+	}
+
+	if categoryID, ok := newProduct.CategoryID(); ok {
+		id := int64(categoryID)
+		product.CategoryID = &id
+	}
+
+	for _, record := range newProduct.Variants() {
+		variant, err := newVariant(&record)
+		if err != nil {
+			return nil, err
+		}
+
+		product.Variants = append(product.Variants, variant)
+
+		if product.Price == 0 || variant.Price < product.Price {
+			product.Price = variant.Price
+		}
 	}
 
 	return &product, nil
 }
 
-func (m ProductModel) GetAll(productName string, price int, filters Filters) ([]*Product, Metadata, error) {
+// productSortColumns maps the sort values accepted by GetAll to the columns
+// they order by.
+var productSortColumns = map[string]string{
+	"id":     `p."id"`,
+	"name":   `p."name"`,
+	"price":  `v."price"`,
+	"rating": `p."rating"`,
+}
+
+// GetAll returns a page of products whose cheapest variant costs at most
+// price. Products without variants cannot be bought and are left out. If
+// query is not empty, only products whose name or description match it are returned, and
+// they can be sorted by how well they match. If categoryID is not zero, only
+// products in that category or one of its subcategories are returned.
+func (m ProductModel) GetAll(query string, price int, categoryID int64, filters Filters) ([]*Product, Metadata, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	totalRecords, err := m.DB.Prisma.ExecuteRaw(`SELECT count(*) as total FROM "Product"`).Exec(ctx)
+	orderBy := `"rank" DESC`
+	if column, ok := productSortColumns[filters.sortColumn()]; ok {
+		direction := "ASC"
+		if filters.sortDirection() == db.SortOrderDesc {
+			direction = "DESC"
+		}
+		orderBy = column + " " + direction
+	}
+
+	sql := fmt.Sprintf(`
+		WITH search AS (
+			SELECT websearch_to_tsquery('english', $1::text) AS "query"
+		)
+		SELECT count(*) OVER()::int AS "total", p."id", p."createdAt", p."name", p."description",
+			v."price", p."rating", p."ratingCount", p."image", p."categoryId", p."version",
+			CASE WHEN $1::text = '' THEN 0 ELSE ts_rank(p."searchVector", s."query") END AS "rank"
+		FROM "Product" p CROSS JOIN search s
+		CROSS JOIN LATERAL (
+			SELECT min("price")::int AS "price" FROM "ProductVariant"
+			WHERE "productId" = p."id" AND "archivedAt" IS NULL
+		) v
+		WHERE p."archivedAt" IS NULL AND v."price" <= $2::int
+		AND ($1::text = '' OR p."searchVector" @@ s."query")
+		AND ($3::int = 0 OR p."categoryId" IN (%s))
+		ORDER BY %s, p."id" ASC
+		LIMIT $4::int OFFSET $5::int`, categoryTreeSQL("$3"), orderBy)
+
+	var rows []struct {
+		Total       int       `json:"total"`
+		ID          int       `json:"id"`
+		CreatedAt   time.Time `json:"createdAt"`
+		Name        string    `json:"name"`
+		Description string    `json:"description"`
+		Price       int       `json:"price"`
+		Rating      float64   `json:"rating"`
+		RatingCount int       `json:"ratingCount"`
+		Image       *string   `json:"image"`
+		CategoryID  *int      `json:"categoryId"`
+		Version     int       `json:"version"`
+	}
+
+	err := m.DB.Prisma.QueryRaw(
+		sql, query, price, int(categoryID), filters.limit(), filters.offset(),
+	).Exec(ctx, &rows)
 	if err != nil {
 		return nil, Metadata{}, err
 	}
 
-	var records []db.ProductModel
-
-	switch filters.sortColumn() {
-	case "price":
-		records, err = m.DB.Product.FindMany(
-			db.Product.Price.LTE(price),
-		).Take(filters.limit()).Skip(filters.offset()).OrderBy(
-			db.Product.Price.Order(filters.sortDirection()),
-		).Exec(ctx)
-	case "rating":
-		records, err = m.DB.Product.FindMany(
-			db.Product.Price.LTE(price),
-		).Take(filters.limit()).Skip(filters.offset()).OrderBy(
-			db.Product.Rating.Order(filters.sortDirection()),
-		).Exec(ctx)
-	case "name":
-		records, err = m.DB.Product.FindMany().Take(filters.limit()).Skip(filters.offset()).OrderBy(
-			db.Product.Name.Order(filters.sortDirection()),
-		).Exec(ctx)
-	default:
-		records, err = m.DB.Product.FindMany(
-			db.Product.Price.LTE(price),
-		).Take(filters.limit()).Skip(filters.offset()).OrderBy(
-			db.Product.ID.Order(filters.sortDirection()),
-		).Exec(ctx)
-	}
-
-	fmt.Println(err)
+	totalRecords := 0
+	products := []*Product{}
+
+	for _, row := range rows {
+		totalRecords = row.Total
+
+		product := &Product{
+			ID:          int64(row.ID),
+			CreatedAt:   row.CreatedAt,
+			Name:        row.Name,
+			Description: row.Description,
+			Price:       uint64(row.Price),
+			Rating:      row.Rating,
+			RatingCount: row.RatingCount,
+			Image:       row.Image,
+			Version:     row.Version,
+		}
 
-	if err != nil {
-		switch {
-		case errors.Is(err, db.ErrNotFound):
-			return nil, Metadata{}, ErrRecordNotFound
-		default:
-			return nil, Metadata{}, err
+		if row.CategoryID != nil {
+			id := int64(*row.CategoryID)
+			product.CategoryID = &id
 		}
+
+		products = append(products, product)
 	}
 
-	var products []*Product
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
 
-	for _, product := range records {
-		createdAt, ok := product.CreatedAt()
-		if !ok {
-			return nil, Metadata{}, errors.New("error accessing created at")
-		}
+	return products, metadata, nil
+}
 
-		image, ok := product.Image()
-		if !ok {
-			return nil, Metadata{}, errors.New("error accessing image")
-		}
-		record := Product{
-			ID:        int64(product.ID),
-			CreatedAt: createdAt,
-			Name:      productName,
-			Price:     uint64(product.Price),
-			Rating:    uint8(product.Rating),
-			Image:     &image,
-		}
+// Update saves the product's details and bumps its version. If someone else
+// saved the product after it was read, nothing is written and ErrEditConflict
+// is returned.
+func (m ProductModel) Update(product *Product) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
 
-		products = append(products, &record)
+	var categoryID *int
+	if product.CategoryID != nil {
+		id := int(*product.CategoryID)
+		categoryID = &id
 	}
 
-	metadata := calculateMetadata(totalRecords.Count, filters.Page, filters.PageSize)
+	result, err := m.DB.Product.FindMany(
+		db.Product.ID.Equals(int(product.ID)),
+		db.Product.ArchivedAt.IsNull(),
+		db.Product.Version.Equals(product.Version),
+	).Update(
+		db.Product.Name.Set(product.Name),
+		db.Product.Image.SetOptional(product.Image),
+		db.Product.Description.Set(product.Description),
+		db.Product.CategoryID.SetOptional(categoryID),
+		db.Product.Version.Increment(1),
+	).Exec(ctx)
+
+	if err != nil {
+		return err
+	}
 
-	return products, metadata, err
+	if result.Count == 0 {
+		return ErrEditConflict
+	}
+
+	product.Version++
+
+	return nil
 }
 
 func ValidateProduct(v *validator.Validator, product *Product) {
@@ -182,10 +309,9 @@ func ValidateProduct(v *validator.Validator, product *Product) {
 	v.Check(len(product.Name) >= 3, "product_name", "must contains atleast 3 bytes")
 	v.Check(len(product.Name) <= 30, "product_name", "must not contains more than 30 bytes")
 
-	v.Check(product.Price > 0, "product_price", "must be a positive number")
-
-	v.Check(int(product.Rating) > 0, "product_rating", "must be greater than 0")
-	v.Check(int(product.Rating) < 5, "product_rating", "must not be greater than 5")
+	v.Check(len(product.Description) <= 5000, "description", "must not be more than 5000 bytes")
 
-	v.Check(validator.Matches(*product.Image, validator.LinkRX), "image", "must be a valid link")
+	if product.Image != nil {
+		v.Check(validator.Matches(*product.Image, validator.LinkRX), "image", "must be a valid link")
+	}
 }
@@ -0,0 +1,353 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/steebchen/prisma-client-go/runtime/transaction"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/prisma/db"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/validator"
+)
+
+const (
+	ReviewStatusPending  = "pending"
+	ReviewStatusApproved = "approved"
+	ReviewStatusRejected = "rejected"
+)
+
+var ReviewStatuses = []string{
+	ReviewStatusPending,
+	ReviewStatusApproved,
+	ReviewStatusRejected,
+}
+
+type Review struct {
+	ID              int64              `json:"id"`
+	ProductID       int64              `json:"product_id"`
+	UserID          int64              `json:"user_id"`
+	Rating          int                `json:"rating"`
+	Body            string             `json:"body"`
+	Status          string             `json:"status"`
+	RejectionReason *string            `json:"rejection_reason,omitempty"`
+	ModeratorID     *int64             `json:"moderator_id,omitempty"`
+	ModeratedAt     *time.Time         `json:"moderated_at,omitempty"`
+	CreatedAt       time.Time          `json:"created_at"`
+	Moderations     []ReviewModeration `json:"moderations,omitempty"`
+}
+
+// ReviewModeration is one decision taken on a review. Every decision is kept,
+// so a review that was rejected and later approved still shows why it was
+// rejected and by whom.
+type ReviewModeration struct {
+	ID          int64     `json:"id"`
+	Status      string    `json:"status"`
+	Reason      *string   `json:"reason,omitempty"`
+	ModeratorID *int64    `json:"moderator_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func newReviewModeration(record *db.ReviewModerationModel) ReviewModeration {
+	moderation := ReviewModeration{
+		ID:        int64(record.ID),
+		Status:    record.Status,
+		CreatedAt: record.CreatedAt,
+	}
+
+	if reason, ok := record.Reason(); ok {
+		moderation.Reason = &reason
+	}
+
+	if moderatorID, ok := record.ModeratorID(); ok {
+		id := int64(moderatorID)
+		moderation.ModeratorID = &id
+	}
+
+	return moderation
+}
+
+func newReview(record *db.ReviewModel) *Review {
+	review := &Review{
+		ID:        int64(record.ID),
+		ProductID: int64(record.ProductID),
+		UserID:    int64(record.UserID),
+		Rating:    record.Rating,
+		Body:      record.Body,
+		Status:    record.Status,
+		CreatedAt: record.CreatedAt,
+	}
+
+	if reason, ok := record.RejectionReason(); ok {
+		review.RejectionReason = &reason
+	}
+
+	if moderatorID, ok := record.ModeratorID(); ok {
+		id := int64(moderatorID)
+		review.ModeratorID = &id
+	}
+
+	if moderatedAt, ok := record.ModeratedAt(); ok {
+		review.ModeratedAt = &moderatedAt
+	}
+
+	return review
+}
+
+func ValidateReview(v *validator.Validator, review *Review) {
+	v.Check(review.Rating >= 1, "rating", "must be at least 1")
+	v.Check(review.Rating <= 5, "rating", "must not be more than 5")
+
+	v.Check(review.Body != "", "body", "must be provided")
+	v.Check(len(review.Body) <= 2000, "body", "must not be more than 2000 bytes")
+}
+
+// ValidateReviewModeration checks an admin's decision on a review. Rejections
+// must say why, so they can be audited later.
+func ValidateReviewModeration(v *validator.Validator, status, reason string) {
+	v.Check(validator.In(status, ReviewStatusApproved, ReviewStatusRejected), "status", "must be approved or rejected")
+
+	if status == ReviewStatusRejected {
+		v.Check(reason != "", "reason", "must be provided")
+	}
+	v.Check(len(reason) <= 500, "reason", "must not be more than 500 bytes")
+}
+
+type ReviewModel struct {
+	DB *db.PrismaClient
+}
+
+// refreshRating returns the operations that recompute the average rating and
+// review count of a product from its approved reviews. The product row is
+// locked first, so the recompute runs in a statement that starts after any
+// other moderation of the product's reviews has committed and counts it.
+// Without the lock, a transaction waiting on the row would write an average
+// from a snapshot taken before the other one committed.
+func refreshRating(client *db.PrismaClient, productID int64) []transaction.Param {
+	lock := client.Prisma.ExecuteRaw(
+		`SELECT 1 FROM "Product" WHERE "id" = $1 FOR UPDATE`, int(productID),
+	).Tx()
+
+	update := client.Prisma.ExecuteRaw(`
+		UPDATE "Product" p
+		SET "rating" = r."average", "ratingCount" = r."count"
+		FROM (
+			SELECT coalesce(avg("rating"), 0)::float AS "average", count(*)::int AS "count"
+			FROM "Review" WHERE "productId" = $1 AND "status" = 'approved'
+		) r
+		WHERE p."id" = $1`, int(productID),
+	).Tx()
+
+	return []transaction.Param{lock, update}
+}
+
+// isVerifiedBuyer reports whether the user has had an order containing the
+// product delivered to them.
+func (m ReviewModel) isVerifiedBuyer(ctx context.Context, userID, productID int64) (bool, error) {
+	_, err := m.DB.OrderItem.FindFirst(
+		db.OrderItem.ProductID.Equals(int(productID)),
+		db.OrderItem.Order.Where(
+			db.Orders.UserID.Equals(int(userID)),
+			db.Orders.Status.Equals(OrderStatusDelivered),
+		),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// Insert saves a review, pending moderation. Only users who have received the
+// product may review it, and only once.
+func (m ReviewModel) Insert(review *Review) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	verified, err := m.isVerifiedBuyer(ctx, review.UserID, review.ProductID)
+	if err != nil {
+		return err
+	}
+
+	if !verified {
+		return ErrNotVerifiedBuyer
+	}
+
+	createReview := m.DB.Review.CreateOne(
+		db.Review.Product.Link(
+			db.Product.ID.Equals(int(review.ProductID)),
+		),
+		db.Review.User.Link(
+			db.User.ID.Equals(int(review.UserID)),
+		),
+		db.Review.Rating.Set(review.Rating),
+		db.Review.Body.Set(review.Body),
+	)
+
+	record, err := createReview.Exec(ctx)
+	if err != nil {
+		if _, isErr := db.IsErrUniqueConstraint(err); isErr {
+			return ErrDuplicateReview
+		}
+		return err
+	}
+
+	review.ID = int64(record.ID)
+	review.Status = record.Status
+	review.CreatedAt = record.CreatedAt
+
+	return nil
+}
+
+// Get returns a review whatever its status, with its moderation history.
+func (m ReviewModel) Get(reviewID int64) (*Review, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	record, err := m.DB.Review.FindUnique(
+		db.Review.ID.Equals(int(reviewID)),
+	).With(
+		db.Review.Moderations.Fetch().OrderBy(
+			db.ReviewModeration.ID.Order(db.SortOrderAsc),
+		),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	review := newReview(record)
+
+	for _, moderation := range record.Moderations() {
+		review.Moderations = append(review.Moderations, newReviewModeration(&moderation))
+	}
+
+	return review, nil
+}
+
+// GetAll returns a page of reviews with the given status. If productID is not
+// zero, only reviews of that product are returned.
+func (m ReviewModel) GetAll(productID int64, status string, filters Filters) ([]*Review, Metadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var count []struct {
+		Total int `json:"total"`
+	}
+
+	err := m.DB.Prisma.QueryRaw(
+		`SELECT count(*)::int AS total FROM "Review" WHERE ($1::int = 0 OR "productId" = $1::int) AND "status" = $2`,
+		int(productID), status,
+	).Exec(ctx, &count)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	where := []db.ReviewWhereParam{
+		db.Review.Status.Equals(status),
+	}
+	if productID != 0 {
+		where = append(where, db.Review.ProductID.Equals(int(productID)))
+	}
+
+	var orderBy db.ReviewOrderByParam
+
+	switch filters.sortColumn() {
+	case "rating":
+		orderBy = db.Review.Rating.Order(filters.sortDirection())
+	default:
+		orderBy = db.Review.ID.Order(filters.sortDirection())
+	}
+
+	records, err := m.DB.Review.FindMany(where...).Take(filters.limit()).Skip(filters.offset()).OrderBy(
+		orderBy,
+	).Exec(ctx)
+
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	reviews := []*Review{}
+	for _, record := range records {
+		reviews = append(reviews, newReview(&record))
+	}
+
+	var totalRecords int
+	if len(count) > 0 {
+		totalRecords = count[0].Total
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return reviews, metadata, nil
+}
+
+// Moderate approves or rejects a review and recomputes the product's rating
+// in the same transaction, so the rating only ever counts approved reviews.
+// An approved review may later be rejected and the other way round. The
+// review holds the latest decision; each decision, with its reason if one was
+// given, is also added to the review's moderation history.
+func (m ReviewModel) Moderate(reviewID int64, status string, moderatorID int64, reason string) (*Review, error) {
+	review, err := m.Get(reviewID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var givenReason, rejectionReason *string
+	if reason != "" {
+		givenReason = &reason
+	}
+	if status == ReviewStatusRejected {
+		rejectionReason = givenReason
+	}
+
+	updateReview := m.DB.Review.FindUnique(
+		db.Review.ID.Equals(int(reviewID)),
+	).Update(
+		db.Review.Status.Set(status),
+		db.Review.RejectionReason.SetOptional(rejectionReason),
+		db.Review.Moderator.Link(
+			db.User.ID.Equals(int(moderatorID)),
+		),
+		db.Review.ModeratedAt.Set(time.Now()),
+	).Tx()
+
+	recordDecision := m.DB.ReviewModeration.CreateOne(
+		db.ReviewModeration.Review.Link(
+			db.Review.ID.Equals(int(reviewID)),
+		),
+		db.ReviewModeration.Status.Set(status),
+		db.ReviewModeration.Reason.SetOptional(givenReason),
+		db.ReviewModeration.Moderator.Link(
+			db.User.ID.Equals(int(moderatorID)),
+		),
+	).Tx()
+
+	ops := []transaction.Param{updateReview, recordDecision}
+	ops = append(ops, refreshRating(m.DB, review.ProductID)...)
+
+	err = m.DB.Prisma.Transaction(ops...).Exec(ctx)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return m.Get(reviewID)
+}
@@ -0,0 +1,71 @@
+package data
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vaidik-bajpai/ecommerce-api/internal/validator"
+)
+
+func TestValidateReview(t *testing.T) {
+	tests := []struct {
+		name      string
+		review    Review
+		wantField string
+	}{
+		{name: "valid", review: Review{Rating: 4, Body: "Fits well."}},
+		{name: "lowest rating", review: Review{Rating: 1, Body: "Fell apart."}},
+		{name: "highest rating", review: Review{Rating: 5, Body: "Great."}},
+		{name: "longest body", review: Review{Rating: 3, Body: strings.Repeat("a", 2000)}},
+		{name: "no rating", review: Review{Body: "Fine."}, wantField: "rating"},
+		{name: "rating too high", review: Review{Rating: 6, Body: "Fine."}, wantField: "rating"},
+		{name: "negative rating", review: Review{Rating: -1, Body: "Fine."}, wantField: "rating"},
+		{name: "no body", review: Review{Rating: 3}, wantField: "body"},
+		{name: "body too long", review: Review{Rating: 3, Body: strings.Repeat("a", 2001)}, wantField: "body"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.NewValidator()
+			ValidateReview(v, &tt.review)
+
+			if tt.wantField == "" {
+				if !v.Valid() {
+					t.Errorf("got errors %v; want none", v.Errors)
+				}
+				return
+			}
+
+			if len(v.Errors) != 1 || v.Errors[tt.wantField] == "" {
+				t.Errorf("got errors %v; want one for %q", v.Errors, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestValidateReviewModeration(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		reason string
+		want   bool
+	}{
+		{name: "approve", status: ReviewStatusApproved, want: true},
+		{name: "approve with a reason", status: ReviewStatusApproved, reason: "Checked the order.", want: true},
+		{name: "reject with a reason", status: ReviewStatusRejected, reason: "Off topic.", want: true},
+		{name: "reject without a reason", status: ReviewStatusRejected},
+		{name: "reason too long", status: ReviewStatusRejected, reason: strings.Repeat("a", 501)},
+		{name: "back to pending", status: ReviewStatusPending},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.NewValidator()
+			ValidateReviewModeration(v, tt.status, tt.reason)
+
+			if v.Valid() != tt.want {
+				t.Errorf("got valid %t; want %t (errors: %v)", v.Valid(), tt.want, v.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/vaidik-bajpai/ecommerce-api/internal/prisma/db"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/validator"
+)
+
+const (
+	ScopeRefresh       = "refresh"
+	ScopeActivation    = "activation"
+	ScopePasswordReset = "password-reset"
+)
+
+// Token is a one-time secret handed to a client. Only the SHA-256 hash of the
+// plaintext is stored. Refresh tokens also carry the id of the login session
+// they belong to, which access tokens reference so they can be revoked.
+type Token struct {
+	Plaintext string    `json:"token"`
+	Hash      []byte    `json:"-"`
+	UserID    int64     `json:"-"`
+	Expiry    time.Time `json:"expiry"`
+	Scope     string    `json:"-"`
+	Session   string    `json:"-"`
+}
+
+func generateToken(userID int64, ttl time.Duration, scope string) (*Token, error) {
+	token := &Token{
+		UserID: userID,
+		Expiry: time.Now().Add(ttl),
+		Scope:  scope,
+	}
+
+	randomBytes := make([]byte, 16)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	token.Plaintext = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+
+	hash := sha256.Sum256([]byte(token.Plaintext))
+	token.Hash = hash[:]
+
+	return token, nil
+}
+
+func newSessionID() (string, error) {
+	randomBytes := make([]byte, 16)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(randomBytes), nil
+}
+
+func ValidateTokenPlaintext(v *validator.Validator, tokenPlaintext string) {
+	v.Check(tokenPlaintext != "", "token", "must be provided")
+	v.Check(len(tokenPlaintext) == 26, "token", "must be 26 bytes long")
+}
+
+type TokenModel struct {
+	DB *db.PrismaClient
+}
+
+func (m TokenModel) createTx(token *Token) db.TokenUniqueTxResult {
+	params := []db.TokenSetParam{}
+	if token.Session != "" {
+		params = append(params, db.Token.Session.Set(token.Session))
+	}
+
+	return m.DB.Token.CreateOne(
+		db.Token.Hash.Set(token.Hash),
+		db.Token.User.Link(
+			db.User.ID.Equals(int(token.UserID)),
+		),
+		db.Token.Expiry.Set(token.Expiry),
+		db.Token.Scope.Set(token.Scope),
+		params...,
+	).Tx()
+}
+
+func (m TokenModel) New(userID int64, ttl time.Duration, scope string) (*Token, error) {
+	token, err := generateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Insert(token)
+	return token, err
+}
+
+// NewSession starts a login session for the user and returns its first
+// refresh token.
+func (m TokenModel) NewSession(userID int64, ttl time.Duration) (*Token, error) {
+	token, err := generateToken(userID, ttl, ScopeRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	token.Session, err = newSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Insert(token)
+	return token, err
+}
+
+func (m TokenModel) Insert(token *Token) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.Prisma.Transaction(m.createTx(token)).Exec(ctx)
+}
+
+// Rotate exchanges a refresh token for a new one in the same session. The old
+// token is kept but marked as rotated in the same transaction, so it can only
+// be exchanged once. A rotated token being presented again means it has
+// leaked, so the whole session is revoked and ErrRefreshTokenReused returned.
+func (m TokenModel) Rotate(tokenPlaintext string, ttl time.Duration) (*Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	hash := sha256.Sum256([]byte(tokenPlaintext))
+
+	record, err := m.DB.Token.FindFirst(
+		db.Token.Hash.Equals(hash[:]),
+		db.Token.Scope.Equals(ScopeRefresh),
+		db.Token.Expiry.GT(time.Now()),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	session, ok := record.Session()
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	if _, rotated := record.RotatedAt(); rotated {
+		return nil, m.revokeReused(ctx, session)
+	}
+
+	token, err := generateToken(int64(record.UserID), ttl, ScopeRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	token.Session = session
+
+	markRotated := m.DB.Token.FindMany(
+		db.Token.Hash.Equals(hash[:]),
+		db.Token.RotatedAt.IsNull(),
+	).Update(
+		db.Token.RotatedAt.Set(time.Now()),
+	).Tx()
+
+	err = m.DB.Prisma.Transaction(markRotated, m.createTx(token)).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Another request rotated the same token in the meantime.
+	if markRotated.Result().Count == 0 {
+		return nil, m.revokeReused(ctx, session)
+	}
+
+	return token, nil
+}
+
+// revokeReused ends a session whose refresh token was presented after it had
+// already been rotated, and returns ErrRefreshTokenReused.
+func (m TokenModel) revokeReused(ctx context.Context, session string) error {
+	_, err := m.DB.Token.FindMany(
+		db.Token.Session.Equals(session),
+	).Delete().Exec(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	return ErrRefreshTokenReused
+}
+
+// DeleteSession revokes the login session that the refresh token belongs to,
+// which also stops the session's access tokens from working.
+func (m TokenModel) DeleteSession(tokenPlaintext string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	hash := sha256.Sum256([]byte(tokenPlaintext))
+
+	record, err := m.DB.Token.FindFirst(
+		db.Token.Hash.Equals(hash[:]),
+		db.Token.Scope.Equals(ScopeRefresh),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	session, ok := record.Session()
+	if !ok {
+		return ErrRecordNotFound
+	}
+
+	_, err = m.DB.Token.FindMany(
+		db.Token.Session.Equals(session),
+	).Delete().Exec(ctx)
+
+	return err
+}
+
+func (m TokenModel) DeleteAllForUser(scope string, userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.Token.FindMany(
+		db.Token.Scope.Equals(scope),
+		db.Token.UserID.Equals(int(userID)),
+	).Delete().Exec(ctx)
+
+	return err
+}
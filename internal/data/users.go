@@ -2,6 +2,7 @@ package data
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
 	"time"
 
@@ -46,16 +47,18 @@ func (p *password) Matches(plaintextPassword string) (bool, error) {
 var AnonymousUser = &User{}
 
 type User struct {
-	ID        int64     `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	FirstName *string   `json:"firstname"`
-	LastName  *string   `json:"lastname"`
-	Password  password  `json:"-"`
-	Email     *string   `json:"email"`
-	Phone     *string   `json:"phone"`
-	Version   int       `json:"version"`
-	Addresses []Address `json:"addresses,omitempty"`
-	Cart      []Product `json:"cart"`
+	ID          int64       `json:"id"`
+	CreatedAt   time.Time   `json:"created_at"`
+	FirstName   *string     `json:"firstname"`
+	LastName    *string     `json:"lastname"`
+	Password    password    `json:"-"`
+	Email       *string     `json:"email"`
+	Phone       *string     `json:"phone"`
+	Activated   bool        `json:"activated"`
+	Version     int         `json:"version"`
+	Permissions Permissions `json:"permissions"`
+	Addresses   []Address   `json:"addresses,omitempty"`
+	Cart        []Product   `json:"cart"`
 }
 
 func (u *User) IsAnonymous() bool {
@@ -140,7 +143,9 @@ func (m UserModel) Insert(user *User) error {
 	}
 
 	user.ID = int64(newUser.ID)
+	user.Activated = newUser.Activated
 	user.Version = newUser.Version
+	user.Permissions = newUser.Permissions
 	createdAt, ok := newUser.CreatedAt()
 	if !ok {
 		return err
@@ -161,7 +166,12 @@ func (m UserModel) GetByEmail(email string) (*User, error) {
 	).Exec(ctx)
 
 	if err != nil {
-		return nil, err
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
 	}
 
 	createdAt, ok := newUser.CreatedAt()
@@ -170,13 +180,15 @@ func (m UserModel) GetByEmail(email string) (*User, error) {
 	}
 
 	user = User{
-		ID:        int64(newUser.ID),
-		FirstName: &newUser.FirstName,
-		LastName:  &newUser.LastName,
-		Email:     &newUser.Email,
-		Phone:     &newUser.Phone,
-		Version:   newUser.Version,
-		CreatedAt: createdAt,
+		ID:          int64(newUser.ID),
+		FirstName:   &newUser.FirstName,
+		LastName:    &newUser.LastName,
+		Email:       &newUser.Email,
+		Phone:       &newUser.Phone,
+		Activated:   newUser.Activated,
+		Version:     newUser.Version,
+		Permissions: newUser.Permissions,
+		CreatedAt:   createdAt,
 	}
 	user.Password.hash = newUser.Password
 
@@ -192,19 +204,185 @@ func (m UserModel) Get(userID int64) (*User, error) {
 	).Exec(ctx)
 
 	if err != nil {
-		return nil, err
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
 	}
 
-	var user = &User{
-		ID:        int64(newUser.ID),
-		FirstName: &newUser.FirstName,
-		LastName:  &newUser.LastName,
-		Email:     &newUser.Email,
-		Phone:     &newUser.Phone,
-		Version:   newUser.Version,
+	return userFromRecord(newUser), nil
+}
+
+// GetForSession returns the user an access token was issued to, as long as
+// the token's login session still has a live refresh token. Checking the
+// session in the same query as loading the user keeps authentication to one
+// query per request while still letting a logout revoke access tokens.
+func (m UserModel) GetForSession(userID int64, session string) (*User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	record, err := m.DB.User.FindFirst(
+		db.User.ID.Equals(int(userID)),
+		db.User.Tokens.Some(
+			db.Token.Session.Equals(session),
+			db.Token.Scope.Equals(ScopeRefresh),
+			db.Token.Expiry.GT(time.Now()),
+			db.Token.RotatedAt.IsNull(),
+		),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
 	}
 
-	user.Password.hash = newUser.Password
+	return userFromRecord(record), nil
+}
 
-	return user, nil
+func userFromRecord(record *db.UserModel) *User {
+	user := &User{
+		ID:          int64(record.ID),
+		FirstName:   &record.FirstName,
+		LastName:    &record.LastName,
+		Email:       &record.Email,
+		Phone:       &record.Phone,
+		Activated:   record.Activated,
+		Version:     record.Version,
+		Permissions: record.Permissions,
+	}
+
+	user.Password.hash = record.Password
+
+	return user
+}
+
+// GetForToken returns the user a token was issued to, as long as the token
+// has the given scope and has not expired.
+func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	token, err := m.DB.Token.FindFirst(
+		db.Token.Hash.Equals(tokenHash[:]),
+		db.Token.Scope.Equals(tokenScope),
+		db.Token.Expiry.GT(time.Now()),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return m.Get(int64(token.UserID))
+}
+
+func (m UserModel) Activate(user *User) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	updatedUser, err := m.DB.User.FindUnique(
+		db.User.ID.Equals(int(user.ID)),
+	).Update(
+		db.User.Activated.Set(true),
+		db.User.Version.Increment(1),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	user.Activated = updatedUser.Activated
+	user.Version = updatedUser.Version
+
+	return nil
+}
+
+// UpdatePassword saves the user's new password hash and bumps their version.
+func (m UserModel) UpdatePassword(user *User) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	updatedUser, err := m.DB.User.FindUnique(
+		db.User.ID.Equals(int(user.ID)),
+	).Update(
+		db.User.Password.Set(user.Password.hash),
+		db.User.Version.Increment(1),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	user.Version = updatedUser.Version
+
+	return nil
+}
+
+// Update saves the user's profile, as long as their version has not changed
+// since they were read. Otherwise it returns ErrEditConflict.
+func (m UserModel) Update(user *User) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.User.FindMany(
+		db.User.ID.Equals(int(user.ID)),
+		db.User.Version.Equals(user.Version),
+	).Update(
+		db.User.FirstName.Set(*user.FirstName),
+		db.User.LastName.Set(*user.LastName),
+		db.User.Email.Set(*user.Email),
+		db.User.Phone.Set(*user.Phone),
+		db.User.Activated.Set(user.Activated),
+		db.User.Version.Increment(1),
+	).Exec(ctx)
+
+	if err != nil {
+		infoUnique, isErr := db.IsErrUniqueConstraint(err)
+
+		switch {
+		case isErr:
+			for _, field := range infoUnique.Fields {
+				if field == "email" {
+					return ErrDuplicateEmail
+				} else if field == "phone" {
+					return ErrDuplicatePhoneNo
+				} else {
+					return errors.New("unique constraint violated")
+				}
+			}
+			return err
+		default:
+			return err
+		}
+	}
+
+	if result.Count == 0 {
+		return ErrEditConflict
+	}
+
+	user.Version++
+
+	return nil
 }
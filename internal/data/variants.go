@@ -0,0 +1,194 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/vaidik-bajpai/ecommerce-api/internal/prisma/db"
+	"github.com/vaidik-bajpai/ecommerce-api/internal/validator"
+)
+
+// Variant is a sellable version of a product, such as a size or colour. Each
+// variant has its own SKU, price, image and stock.
+type Variant struct {
+	ID         int64             `json:"id"`
+	ProductID  int64             `json:"product_id"`
+	SKU        string            `json:"sku"`
+	Attributes map[string]string `json:"attributes"`
+	Price      uint64            `json:"price"`
+	Image      *string           `json:"image"`
+	OnHand     int               `json:"on_hand"`
+	Reserved   int               `json:"reserved"`
+	Version    int               `json:"version"`
+}
+
+func newVariant(record *db.ProductVariantModel) (*Variant, error) {
+	variant := &Variant{
+		ID:         int64(record.ID),
+		ProductID:  int64(record.ProductID),
+		SKU:        record.Sku,
+		Attributes: map[string]string{},
+		Price:      uint64(record.Price),
+		OnHand:     record.OnHand,
+		Reserved:   record.Reserved,
+		Version:    record.Version,
+	}
+
+	err := json.Unmarshal(record.Attributes, &variant.Attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	if image, ok := record.Image(); ok {
+		variant.Image = &image
+	}
+
+	return variant, nil
+}
+
+func ValidateVariant(v *validator.Validator, variant *Variant) {
+	v.Check(variant.SKU != "", "sku", "must be provided")
+	v.Check(len(variant.SKU) <= 40, "sku", "must not be more than 40 bytes")
+	v.Check(validator.Matches(variant.SKU, validator.SKURX), "sku", "must only contain letters, digits and dashes")
+
+	v.Check(len(variant.Attributes) <= 10, "attributes", "must not have more than 10 entries")
+	for name, value := range variant.Attributes {
+		v.Check(name != "" && len(name) <= 30, "attributes", "names must be between 1 and 30 bytes")
+		v.Check(value != "" && len(value) <= 50, "attributes", "values must be between 1 and 50 bytes")
+	}
+
+	v.Check(variant.Price > 0, "price", "must be a positive number")
+
+	if variant.Image != nil {
+		v.Check(validator.Matches(*variant.Image, validator.LinkRX), "image", "must be a valid link")
+	}
+}
+
+type VariantModel struct {
+	DB *db.PrismaClient
+}
+
+func (m VariantModel) Insert(variant *Variant) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	attributes, err := json.Marshal(variant.Attributes)
+	if err != nil {
+		return err
+	}
+
+	record, err := m.DB.ProductVariant.CreateOne(
+		db.ProductVariant.Product.Link(
+			db.Product.ID.Equals(int(variant.ProductID)),
+		),
+		db.ProductVariant.Sku.Set(variant.SKU),
+		db.ProductVariant.Price.Set(int(variant.Price)),
+		db.ProductVariant.Attributes.Set(attributes),
+		db.ProductVariant.Image.SetIfPresent(variant.Image),
+	).Exec(ctx)
+
+	if err != nil {
+		if _, isErr := db.IsErrUniqueConstraint(err); isErr {
+			return ErrDuplicateSKU
+		}
+		return err
+	}
+
+	variant.ID = int64(record.ID)
+	variant.Version = record.Version
+
+	return nil
+}
+
+func (m VariantModel) Get(variantID int64) (*Variant, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	record, err := m.DB.ProductVariant.FindFirst(
+		db.ProductVariant.ID.Equals(int(variantID)),
+		db.ProductVariant.ArchivedAt.IsNull(),
+	).Exec(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return newVariant(record)
+}
+
+// Update saves the variant, as long as its version has not changed since it
+// was read. Otherwise it returns ErrEditConflict. Stock is only changed
+// through AdjustStock and the order flow.
+func (m VariantModel) Update(variant *Variant) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	attributes, err := json.Marshal(variant.Attributes)
+	if err != nil {
+		return err
+	}
+
+	result, err := m.DB.ProductVariant.FindMany(
+		db.ProductVariant.ID.Equals(int(variant.ID)),
+		db.ProductVariant.ArchivedAt.IsNull(),
+		db.ProductVariant.Version.Equals(variant.Version),
+	).Update(
+		db.ProductVariant.Sku.Set(variant.SKU),
+		db.ProductVariant.Attributes.Set(attributes),
+		db.ProductVariant.Price.Set(int(variant.Price)),
+		db.ProductVariant.Image.SetOptional(variant.Image),
+		db.ProductVariant.Version.Increment(1),
+	).Exec(ctx)
+
+	if err != nil {
+		if _, isErr := db.IsErrUniqueConstraint(err); isErr {
+			return ErrDuplicateSKU
+		}
+		return err
+	}
+
+	if result.Count == 0 {
+		return ErrEditConflict
+	}
+
+	variant.Version++
+
+	return nil
+}
+
+// Delete archives a variant and takes it out of any carts. An archived
+// variant is no longer listed or sold, but its row is kept so its stock
+// ledger and the orders that bought it still refer to it.
+func (m VariantModel) Delete(variantID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	archive := m.DB.ProductVariant.FindMany(
+		db.ProductVariant.ID.Equals(int(variantID)),
+		db.ProductVariant.ArchivedAt.IsNull(),
+	).Update(
+		db.ProductVariant.ArchivedAt.Set(time.Now()),
+	).Tx()
+
+	removeFromCarts := m.DB.CartItem.FindMany(
+		db.CartItem.VariantID.Equals(int(variantID)),
+	).Delete().Tx()
+
+	err := m.DB.Prisma.Transaction(archive, removeFromCarts).Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	if archive.Result().Count == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
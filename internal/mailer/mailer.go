@@ -0,0 +1,99 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed "templates"
+var templateFS embed.FS
+
+// Mailer sends an email built from one of the embedded templates. Each
+// template defines a "subject" and a "plainBody" block.
+type Mailer interface {
+	Send(recipient, templateFile string, data any) error
+}
+
+func render(templateFile string, data any) (string, string, error) {
+	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return "", "", err
+	}
+
+	subject := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return "", "", err
+	}
+
+	plainBody := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(plainBody, "plainBody", data)
+	if err != nil {
+		return "", "", err
+	}
+
+	return strings.TrimSpace(subject.String()), plainBody.String(), nil
+}
+
+// LogMailer writes emails to a logger instead of delivering them.
+type LogMailer struct {
+	logger *log.Logger
+	sender string
+}
+
+func NewLogMailer(logger *log.Logger, sender string) LogMailer {
+	return LogMailer{
+		logger: logger,
+		sender: sender,
+	}
+}
+
+func (m LogMailer) Send(recipient, templateFile string, data any) error {
+	subject, plainBody, err := render(templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	m.logger.Printf("email from %s to %s: %s\n%s", m.sender, recipient, subject, plainBody)
+
+	return nil
+}
+
+// FileMailer writes each email to its own file in a directory, so messages
+// can be read back offline.
+type FileMailer struct {
+	dir    string
+	sender string
+}
+
+func NewFileMailer(dir, sender string) FileMailer {
+	return FileMailer{
+		dir:    dir,
+		sender: sender,
+	}
+}
+
+func (m FileMailer) Send(recipient, templateFile string, data any) error {
+	subject, plainBody, err := render(templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(m.dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), strings.TrimSuffix(templateFile, filepath.Ext(templateFile)))
+
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s", m.sender, recipient, subject, plainBody)
+
+	return os.WriteFile(filepath.Join(m.dir, name), []byte(message), 0o644)
+}
@@ -0,0 +1,132 @@
+package payments
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// mockDeclineSuffix makes the mock gateway decline an authorization, so that
+// the decline path can be exercised on purpose: amounts ending in 13 are
+// declined and every other amount is approved.
+const mockDeclineSuffix = 13
+
+const (
+	mockAuthorized = "authorized"
+	mockCaptured   = "captured"
+	mockVoided     = "voided"
+)
+
+type mockTransaction struct {
+	state    string
+	amount   int64
+	captured int64
+	refunded int64
+}
+
+// MockGateway is an in-process gateway for development and tests. It never
+// talks to a provider and always gives the same answer for the same request.
+// Transactions are kept in memory, so they are lost when the process exits.
+type MockGateway struct {
+	mu           sync.Mutex
+	transactions map[string]*mockTransaction
+}
+
+func NewMockGateway() *MockGateway {
+	return &MockGateway{
+		transactions: make(map[string]*mockTransaction),
+	}
+}
+
+func (g *MockGateway) Name() string {
+	return "mock"
+}
+
+// Authorize approves the request unless its amount ends in 13. Authorizing
+// the same order again returns the existing transaction.
+func (g *MockGateway) Authorize(ctx context.Context, req AuthorizeRequest) (*Result, error) {
+	if req.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	transactionID := "mock_" + req.OrderReference
+
+	if req.Amount%100 == mockDeclineSuffix {
+		return &Result{TransactionID: transactionID, Status: StatusDeclined, Message: "declined by the mock gateway"}, nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, exists := g.transactions[transactionID]; !exists {
+		g.transactions[transactionID] = &mockTransaction{state: mockAuthorized, amount: req.Amount}
+	}
+
+	return &Result{TransactionID: transactionID, Status: StatusApproved}, nil
+}
+
+func (g *MockGateway) Capture(ctx context.Context, transactionID string, amount int64) (*Result, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	transaction, ok := g.transactions[transactionID]
+	if !ok {
+		return nil, ErrUnknownTransaction
+	}
+
+	if transaction.state != mockAuthorized {
+		return nil, ErrInvalidState
+	}
+
+	if amount <= 0 || amount > transaction.amount {
+		return nil, ErrInvalidAmount
+	}
+
+	transaction.state = mockCaptured
+	transaction.captured = amount
+
+	return &Result{TransactionID: transactionID, Status: StatusApproved}, nil
+}
+
+func (g *MockGateway) Void(ctx context.Context, transactionID string) (*Result, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	transaction, ok := g.transactions[transactionID]
+	if !ok {
+		return nil, ErrUnknownTransaction
+	}
+
+	if transaction.state != mockAuthorized {
+		return nil, ErrInvalidState
+	}
+
+	transaction.state = mockVoided
+
+	return &Result{TransactionID: transactionID, Status: StatusApproved}, nil
+}
+
+func (g *MockGateway) Refund(ctx context.Context, transactionID string, amount int64) (*Result, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	transaction, ok := g.transactions[transactionID]
+	if !ok {
+		return nil, ErrUnknownTransaction
+	}
+
+	if transaction.state != mockCaptured {
+		return nil, ErrInvalidState
+	}
+
+	if amount <= 0 || amount > transaction.captured-transaction.refunded {
+		return nil, ErrInvalidAmount
+	}
+
+	transaction.refunded += amount
+
+	return &Result{
+		TransactionID: fmt.Sprintf("%s_refund_%d", transactionID, transaction.refunded),
+		Status:        StatusApproved,
+	}, nil
+}
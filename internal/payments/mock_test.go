@@ -0,0 +1,272 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMockGatewayAuthorize(t *testing.T) {
+	tests := []struct {
+		name       string
+		amount     int64
+		wantStatus string
+		wantErr    error
+	}{
+		{name: "approved", amount: 2500, wantStatus: StatusApproved},
+		{name: "ends in 13", amount: 2513, wantStatus: StatusDeclined},
+		{name: "only 13", amount: 13, wantStatus: StatusDeclined},
+		{name: "ends in 113", amount: 113, wantStatus: StatusDeclined},
+		{name: "ends in 31", amount: 2531, wantStatus: StatusApproved},
+		{name: "zero", amount: 0, wantErr: ErrInvalidAmount},
+		{name: "negative", amount: -100, wantErr: ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewMockGateway()
+
+			result, err := g.Authorize(context.Background(), AuthorizeRequest{OrderReference: "1", Amount: tt.amount})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v; want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if result.Status != tt.wantStatus {
+				t.Errorf("got status %q; want %q", result.Status, tt.wantStatus)
+			}
+			if result.TransactionID != "mock_1" {
+				t.Errorf("got transaction id %q; want %q", result.TransactionID, "mock_1")
+			}
+		})
+	}
+}
+
+func TestMockGatewayDeclineKeepsNoTransaction(t *testing.T) {
+	g := NewMockGateway()
+	ctx := context.Background()
+
+	result, err := g.Authorize(ctx, AuthorizeRequest{OrderReference: "1", Amount: 113})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = g.Capture(ctx, result.TransactionID, 113)
+	if !errors.Is(err, ErrUnknownTransaction) {
+		t.Errorf("got error %v; want %v", err, ErrUnknownTransaction)
+	}
+}
+
+func TestMockGatewayAuthorizeTwice(t *testing.T) {
+	g := NewMockGateway()
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		_, err := g.Authorize(ctx, AuthorizeRequest{OrderReference: "1", Amount: 500})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := g.Capture(ctx, "mock_1", 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second authorization must not reset the captured transaction.
+	_, err = g.Authorize(ctx, AuthorizeRequest{OrderReference: "1", Amount: 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = g.Refund(ctx, "mock_1", 500)
+	if err != nil {
+		t.Errorf("refund after a repeated authorization: %v", err)
+	}
+}
+
+func TestMockGatewayStateRules(t *testing.T) {
+	type step struct {
+		op      string
+		amount  int64
+		wantErr error
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "capture then refund",
+			steps: []step{
+				{op: "capture", amount: 1000},
+				{op: "refund", amount: 1000},
+			},
+		},
+		{
+			name: "void",
+			steps: []step{
+				{op: "void"},
+			},
+		},
+		{
+			name: "capture twice",
+			steps: []step{
+				{op: "capture", amount: 1000},
+				{op: "capture", amount: 1000, wantErr: ErrInvalidState},
+			},
+		},
+		{
+			name: "void after capture",
+			steps: []step{
+				{op: "capture", amount: 1000},
+				{op: "void", wantErr: ErrInvalidState},
+			},
+		},
+		{
+			name: "capture after void",
+			steps: []step{
+				{op: "void"},
+				{op: "capture", amount: 1000, wantErr: ErrInvalidState},
+			},
+		},
+		{
+			name: "void twice",
+			steps: []step{
+				{op: "void"},
+				{op: "void", wantErr: ErrInvalidState},
+			},
+		},
+		{
+			name: "refund before capture",
+			steps: []step{
+				{op: "refund", amount: 1000, wantErr: ErrInvalidState},
+			},
+		},
+		{
+			name: "refund after void",
+			steps: []step{
+				{op: "void"},
+				{op: "refund", amount: 1000, wantErr: ErrInvalidState},
+			},
+		},
+		{
+			name: "capture more than authorized",
+			steps: []step{
+				{op: "capture", amount: 1001, wantErr: ErrInvalidAmount},
+				{op: "capture", amount: 1000},
+			},
+		},
+		{
+			name: "capture nothing",
+			steps: []step{
+				{op: "capture", amount: 0, wantErr: ErrInvalidAmount},
+			},
+		},
+		{
+			name: "partial capture",
+			steps: []step{
+				{op: "capture", amount: 600},
+				{op: "refund", amount: 601, wantErr: ErrInvalidAmount},
+				{op: "refund", amount: 600},
+			},
+		},
+		{
+			name: "partial refunds",
+			steps: []step{
+				{op: "capture", amount: 1000},
+				{op: "refund", amount: 400},
+				{op: "refund", amount: 400},
+				{op: "refund", amount: 201, wantErr: ErrInvalidAmount},
+				{op: "refund", amount: 200},
+				{op: "refund", amount: 1, wantErr: ErrInvalidAmount},
+			},
+		},
+		{
+			name: "refund nothing",
+			steps: []step{
+				{op: "capture", amount: 1000},
+				{op: "refund", amount: 0, wantErr: ErrInvalidAmount},
+				{op: "refund", amount: -1, wantErr: ErrInvalidAmount},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewMockGateway()
+			ctx := context.Background()
+
+			authorized, err := g.Authorize(ctx, AuthorizeRequest{OrderReference: "1", Amount: 1000})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, s := range tt.steps {
+				switch s.op {
+				case "capture":
+					_, err = g.Capture(ctx, authorized.TransactionID, s.amount)
+				case "void":
+					_, err = g.Void(ctx, authorized.TransactionID)
+				case "refund":
+					_, err = g.Refund(ctx, authorized.TransactionID, s.amount)
+				}
+
+				if !errors.Is(err, s.wantErr) {
+					t.Fatalf("step %d (%s %d): got error %v; want %v", i, s.op, s.amount, err, s.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestMockGatewayUnknownTransaction(t *testing.T) {
+	g := NewMockGateway()
+	ctx := context.Background()
+
+	_, err := g.Capture(ctx, "mock_missing", 100)
+	if !errors.Is(err, ErrUnknownTransaction) {
+		t.Errorf("capture: got error %v; want %v", err, ErrUnknownTransaction)
+	}
+
+	_, err = g.Void(ctx, "mock_missing")
+	if !errors.Is(err, ErrUnknownTransaction) {
+		t.Errorf("void: got error %v; want %v", err, ErrUnknownTransaction)
+	}
+
+	_, err = g.Refund(ctx, "mock_missing", 100)
+	if !errors.Is(err, ErrUnknownTransaction) {
+		t.Errorf("refund: got error %v; want %v", err, ErrUnknownTransaction)
+	}
+}
+
+func TestMockGatewayRefundIDs(t *testing.T) {
+	g := NewMockGateway()
+	ctx := context.Background()
+
+	authorized, err := g.Authorize(ctx, AuthorizeRequest{OrderReference: "7", Amount: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = g.Capture(ctx, authorized.TransactionID, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := g.Refund(ctx, authorized.TransactionID, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := g.Refund(ctx, authorized.TransactionID, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.TransactionID == second.TransactionID {
+		t.Errorf("both refunds got transaction id %q", first.TransactionID)
+	}
+}
@@ -0,0 +1,46 @@
+package payments
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrUnknownTransaction = errors.New("payments: unknown transaction")
+	ErrInvalidState       = errors.New("payments: transaction cannot be changed in its current state")
+	ErrInvalidAmount      = errors.New("payments: invalid amount")
+)
+
+// The outcomes a gateway can report for an operation. A pending outcome is
+// settled later, when the provider calls back with an event.
+const (
+	StatusApproved = "approved"
+	StatusPending  = "pending"
+	StatusDeclined = "declined"
+)
+
+// AuthorizeRequest asks a gateway to set aside the amount of an order on the
+// customer's payment method. Amounts are in the same units as order prices.
+type AuthorizeRequest struct {
+	OrderReference string
+	Amount         int64
+}
+
+// Result is what a gateway said about an operation. TransactionID identifies
+// the authorization at the provider and is what later operations refer to.
+type Result struct {
+	TransactionID string
+	Status        string
+	Message       string
+}
+
+// Gateway takes money through a payment provider. A declined operation is
+// reported through the result's status, not as an error. Errors mean the
+// operation could not be attempted or was not valid.
+type Gateway interface {
+	Name() string
+	Authorize(ctx context.Context, req AuthorizeRequest) (*Result, error)
+	Capture(ctx context.Context, transactionID string, amount int64) (*Result, error)
+	Void(ctx context.Context, transactionID string) (*Result, error)
+	Refund(ctx context.Context, transactionID string, amount int64) (*Result, error)
+}
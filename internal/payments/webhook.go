@@ -0,0 +1,61 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// SignatureHeader carries the hex encoded HMAC-SHA256 of a webhook's raw
+// body, keyed with the secret shared with the provider. A "sha256=" prefix
+// is accepted.
+const SignatureHeader = "X-Payment-Signature"
+
+// The event types a provider sends to confirm the outcome of an
+// authorization that was left pending.
+const (
+	EventPaymentSucceeded = "payment.succeeded"
+	EventPaymentFailed    = "payment.failed"
+)
+
+// Event is a notification sent by a payment provider. ID is unique per event
+// and is repeated when the provider retries a delivery.
+type Event struct {
+	ID   string    `json:"id"`
+	Type string    `json:"type"`
+	Data EventData `json:"data"`
+}
+
+type EventData struct {
+	TransactionID  string `json:"transaction_id"`
+	OrderReference string `json:"order_reference"`
+	Amount         int64  `json:"amount"`
+	Message        string `json:"message"`
+}
+
+// Sign returns the signature of a payload, as expected in SignatureHeader.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature reports whether signature was made for payload with the
+// secret. Nothing verifies without a secret.
+func VerifySignature(secret string, payload []byte, signature string) bool {
+	if secret == "" || signature == "" {
+		return false
+	}
+
+	signature = strings.TrimPrefix(strings.TrimSpace(signature), "sha256=")
+
+	given, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	expected, _ := hex.DecodeString(Sign(secret, payload))
+
+	return hmac.Equal(given, expected)
+}
@@ -0,0 +1,36 @@
+package payments
+
+import "testing"
+
+func TestVerifySignature(t *testing.T) {
+	const secret = "whsec_test"
+	payload := []byte(`{"id":"evt_1","type":"payment.succeeded"}`)
+	signature := Sign(secret, payload)
+
+	tests := []struct {
+		name      string
+		secret    string
+		payload   []byte
+		signature string
+		want      bool
+	}{
+		{name: "valid", secret: secret, payload: payload, signature: signature, want: true},
+		{name: "prefixed", secret: secret, payload: payload, signature: "sha256=" + signature, want: true},
+		{name: "surrounding space", secret: secret, payload: payload, signature: " " + signature + "\n", want: true},
+		{name: "wrong secret", secret: "whsec_other", payload: payload, signature: signature, want: false},
+		{name: "changed payload", secret: secret, payload: []byte(`{"id":"evt_2","type":"payment.succeeded"}`), signature: signature, want: false},
+		{name: "truncated", secret: secret, payload: payload, signature: signature[:len(signature)-2], want: false},
+		{name: "not hex", secret: secret, payload: payload, signature: "not-a-signature", want: false},
+		{name: "no signature", secret: secret, payload: payload, signature: "", want: false},
+		{name: "no secret", secret: "", payload: payload, signature: Sign("", payload), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := VerifySignature(tt.secret, tt.payload, tt.signature)
+			if got != tt.want {
+				t.Errorf("got %t; want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+/*
+  Warnings:
+
+  - You are about to drop the `_CartProducts` table. Its rows are copied into `CartItem` with a quantity of 1.
+
+*/
+-- CreateTable
+CREATE TABLE "CartItem" (
+    "id" SERIAL NOT NULL,
+    "cartId" INTEGER NOT NULL,
+    "productId" INTEGER NOT NULL,
+    "quantity" INTEGER NOT NULL DEFAULT 1,
+
+    CONSTRAINT "CartItem_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "CartItem_quantity_check" CHECK ("quantity" > 0)
+);
+
+-- CreateIndex
+CREATE UNIQUE INDEX "CartItem_cartId_productId_key" ON "CartItem"("cartId", "productId");
+
+-- AddForeignKey
+ALTER TABLE "CartItem" ADD CONSTRAINT "CartItem_cartId_fkey" FOREIGN KEY ("cartId") REFERENCES "Cart"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "CartItem" ADD CONSTRAINT "CartItem_productId_fkey" FOREIGN KEY ("productId") REFERENCES "Product"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- CopyData
+INSERT INTO "CartItem" ("cartId", "productId", "quantity")
+SELECT "A", "B", 1 FROM "_CartProducts";
+
+-- DropForeignKey
+ALTER TABLE "_CartProducts" DROP CONSTRAINT "_CartProducts_A_fkey";
+
+-- DropForeignKey
+ALTER TABLE "_CartProducts" DROP CONSTRAINT "_CartProducts_B_fkey";
+
+-- DropTable
+DROP TABLE "_CartProducts";
@@ -0,0 +1,25 @@
+-- AlterTable
+ALTER TABLE "Orders" ADD COLUMN     "status" TEXT NOT NULL DEFAULT 'pending_payment';
+
+-- CreateTable
+CREATE TABLE "OrderStatusHistory" (
+    "id" SERIAL NOT NULL,
+    "orderId" INTEGER NOT NULL,
+    "fromStatus" TEXT,
+    "toStatus" TEXT NOT NULL,
+    "actorId" INTEGER,
+    "note" TEXT,
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT "OrderStatusHistory_pkey" PRIMARY KEY ("id")
+);
+
+-- AddForeignKey
+ALTER TABLE "OrderStatusHistory" ADD CONSTRAINT "OrderStatusHistory_orderId_fkey" FOREIGN KEY ("orderId") REFERENCES "Orders"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "OrderStatusHistory" ADD CONSTRAINT "OrderStatusHistory_actorId_fkey" FOREIGN KEY ("actorId") REFERENCES "User"("id") ON DELETE SET NULL ON UPDATE CASCADE;
+
+-- Backfill
+INSERT INTO "OrderStatusHistory" ("orderId", "toStatus", "actorId", "createdAt")
+SELECT "id", "status", "userId", COALESCE("orderedAt", CURRENT_TIMESTAMP) FROM "Orders";
@@ -0,0 +1,2 @@
+-- AlterTable
+ALTER TABLE "Address" ADD COLUMN     "isDefault" BOOLEAN NOT NULL DEFAULT false;
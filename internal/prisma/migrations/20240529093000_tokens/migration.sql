@@ -0,0 +1,16 @@
+-- CreateTable
+CREATE TABLE "Token" (
+    "hash" BYTEA NOT NULL,
+    "userId" INTEGER NOT NULL,
+    "expiry" TIMESTAMP(3) NOT NULL,
+    "scope" TEXT NOT NULL,
+    "session" TEXT,
+
+    CONSTRAINT "Token_pkey" PRIMARY KEY ("hash")
+);
+
+-- CreateIndex
+CREATE INDEX "Token_session_idx" ON "Token"("session");
+
+-- AddForeignKey
+ALTER TABLE "Token" ADD CONSTRAINT "Token_userId_fkey" FOREIGN KEY ("userId") REFERENCES "User"("id") ON DELETE CASCADE ON UPDATE CASCADE;
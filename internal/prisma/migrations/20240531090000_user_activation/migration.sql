@@ -0,0 +1,5 @@
+-- AlterTable
+ALTER TABLE "User" ADD COLUMN     "activated" BOOLEAN NOT NULL DEFAULT false;
+
+-- Accounts that existed before email verification keep working.
+UPDATE "User" SET "activated" = true;
@@ -0,0 +1,46 @@
+-- AlterTable
+ALTER TABLE "Product" ADD COLUMN     "onHand" INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN     "reserved" INTEGER NOT NULL DEFAULT 0;
+
+-- Orders that have not shipped yet hold a reservation on the units they
+-- bought, and those units must be on hand.
+UPDATE "Product" p
+SET "reserved" = o."quantity", "onHand" = o."quantity"
+FROM (
+    SELECT i."productId", sum(i."quantity")::int AS "quantity"
+    FROM "OrderItem" i
+    JOIN "Orders" ord ON ord."id" = i."orderId"
+    WHERE ord."status" IN ('pending_payment', 'paid', 'packed') AND i."productId" IS NOT NULL
+    GROUP BY i."productId"
+) o
+WHERE p."id" = o."productId";
+
+-- AddCheckConstraint
+ALTER TABLE "Product" ADD CONSTRAINT "Product_stock_check" CHECK ("onHand" >= 0 AND "reserved" >= 0 AND "reserved" <= "onHand");
+
+-- CreateTable
+CREATE TABLE "StockMovement" (
+    "id" SERIAL NOT NULL,
+    "productId" INTEGER NOT NULL,
+    "onHandDelta" INTEGER NOT NULL DEFAULT 0,
+    "reservedDelta" INTEGER NOT NULL DEFAULT 0,
+    "reason" TEXT NOT NULL,
+    "note" TEXT,
+    "orderId" INTEGER,
+    "actorId" INTEGER,
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT "StockMovement_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "StockMovement_productId_idx" ON "StockMovement"("productId");
+
+-- AddForeignKey
+ALTER TABLE "StockMovement" ADD CONSTRAINT "StockMovement_productId_fkey" FOREIGN KEY ("productId") REFERENCES "Product"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "StockMovement" ADD CONSTRAINT "StockMovement_orderId_fkey" FOREIGN KEY ("orderId") REFERENCES "Orders"("id") ON DELETE SET NULL ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "StockMovement" ADD CONSTRAINT "StockMovement_actorId_fkey" FOREIGN KEY ("actorId") REFERENCES "User"("id") ON DELETE SET NULL ON UPDATE CASCADE;
@@ -0,0 +1,12 @@
+-- AlterTable
+ALTER TABLE "Product" ADD COLUMN     "description" TEXT NOT NULL DEFAULT '';
+
+-- The search vector is kept up to date by Postgres. Matches in the name
+-- weigh more than matches in the description.
+ALTER TABLE "Product" ADD COLUMN     "searchVector" tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english'::regconfig, "name"), 'A'::"char") ||
+    setweight(to_tsvector('english'::regconfig, "description"), 'B'::"char")
+) STORED;
+
+-- CreateIndex
+CREATE INDEX "Product_searchVector_idx" ON "Product" USING GIN ("searchVector");
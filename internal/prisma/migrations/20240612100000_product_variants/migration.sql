@@ -0,0 +1,98 @@
+/*
+  Warnings:
+
+  - Stock moves from `Product` to `ProductVariant`. Every existing product gets one variant that takes over its price, image and stock.
+  - Cart items, stock movements and order items are pointed at the variant of their product.
+
+*/
+-- CreateTable
+CREATE TABLE "ProductVariant" (
+    "id" SERIAL NOT NULL,
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "productId" INTEGER NOT NULL,
+    "sku" TEXT NOT NULL,
+    "attributes" JSONB NOT NULL DEFAULT '{}',
+    "price" INTEGER NOT NULL,
+    "image" TEXT,
+    "onHand" INTEGER NOT NULL DEFAULT 0,
+    "reserved" INTEGER NOT NULL DEFAULT 0,
+    "version" INTEGER NOT NULL DEFAULT 1,
+
+    CONSTRAINT "ProductVariant_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "ProductVariant_stock_check" CHECK ("onHand" >= 0 AND "reserved" >= 0 AND "reserved" <= "onHand")
+);
+
+-- CreateIndex
+CREATE UNIQUE INDEX "ProductVariant_sku_key" ON "ProductVariant"("sku");
+
+-- CreateIndex
+CREATE INDEX "ProductVariant_productId_idx" ON "ProductVariant"("productId");
+
+-- AddForeignKey
+ALTER TABLE "ProductVariant" ADD CONSTRAINT "ProductVariant_productId_fkey" FOREIGN KEY ("productId") REFERENCES "Product"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- CopyData
+INSERT INTO "ProductVariant" ("productId", "sku", "price", "image", "onHand", "reserved")
+SELECT "id", 'SKU-' || "id", "price", "image", "onHand", "reserved" FROM "Product";
+
+-- AlterTable
+ALTER TABLE "CartItem" ADD COLUMN     "variantId" INTEGER;
+
+UPDATE "CartItem" c SET "variantId" = v."id"
+FROM "ProductVariant" v WHERE v."productId" = c."productId";
+
+ALTER TABLE "CartItem" ALTER COLUMN "variantId" SET NOT NULL;
+
+-- DropForeignKey
+ALTER TABLE "CartItem" DROP CONSTRAINT "CartItem_productId_fkey";
+
+-- DropIndex
+DROP INDEX "CartItem_cartId_productId_key";
+
+-- AlterTable
+ALTER TABLE "CartItem" DROP COLUMN "productId";
+
+-- CreateIndex
+CREATE UNIQUE INDEX "CartItem_cartId_variantId_key" ON "CartItem"("cartId", "variantId");
+
+-- AddForeignKey
+ALTER TABLE "CartItem" ADD CONSTRAINT "CartItem_variantId_fkey" FOREIGN KEY ("variantId") REFERENCES "ProductVariant"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AlterTable
+ALTER TABLE "StockMovement" ADD COLUMN     "variantId" INTEGER;
+
+UPDATE "StockMovement" s SET "variantId" = v."id"
+FROM "ProductVariant" v WHERE v."productId" = s."productId";
+
+ALTER TABLE "StockMovement" ALTER COLUMN "variantId" SET NOT NULL;
+
+-- DropForeignKey
+ALTER TABLE "StockMovement" DROP CONSTRAINT "StockMovement_productId_fkey";
+
+-- DropIndex
+DROP INDEX "StockMovement_productId_idx";
+
+-- AlterTable
+ALTER TABLE "StockMovement" DROP COLUMN "productId";
+
+-- CreateIndex
+CREATE INDEX "StockMovement_variantId_idx" ON "StockMovement"("variantId");
+
+-- AddForeignKey
+ALTER TABLE "StockMovement" ADD CONSTRAINT "StockMovement_variantId_fkey" FOREIGN KEY ("variantId") REFERENCES "ProductVariant"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AlterTable
+ALTER TABLE "OrderItem" ADD COLUMN     "variantId" INTEGER,
+ADD COLUMN     "sku" TEXT;
+
+UPDATE "OrderItem" i SET "variantId" = v."id", "sku" = v."sku"
+FROM "ProductVariant" v WHERE v."productId" = i."productId";
+
+-- AddForeignKey
+ALTER TABLE "OrderItem" ADD CONSTRAINT "OrderItem_variantId_fkey" FOREIGN KEY ("variantId") REFERENCES "ProductVariant"("id") ON DELETE SET NULL ON UPDATE CASCADE;
+
+-- AlterTable
+ALTER TABLE "Product" DROP CONSTRAINT "Product_stock_check";
+
+ALTER TABLE "Product" DROP COLUMN "onHand",
+DROP COLUMN "reserved";
@@ -0,0 +1,34 @@
+/*
+  Warnings:
+
+  - The `rating` column of `Product` becomes the average of its reviews. Ratings typed in by admins are reset to 0.
+
+*/
+-- AlterTable
+ALTER TABLE "Product" ALTER COLUMN "rating" SET DATA TYPE DOUBLE PRECISION,
+ALTER COLUMN "rating" SET DEFAULT 0,
+ADD COLUMN     "ratingCount" INTEGER NOT NULL DEFAULT 0;
+
+UPDATE "Product" SET "rating" = 0;
+
+-- CreateTable
+CREATE TABLE "Review" (
+    "id" SERIAL NOT NULL,
+    "productId" INTEGER NOT NULL,
+    "userId" INTEGER NOT NULL,
+    "rating" INTEGER NOT NULL,
+    "body" TEXT NOT NULL,
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT "Review_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "Review_rating_check" CHECK ("rating" BETWEEN 1 AND 5)
+);
+
+-- CreateIndex
+CREATE UNIQUE INDEX "Review_productId_userId_key" ON "Review"("productId", "userId");
+
+-- AddForeignKey
+ALTER TABLE "Review" ADD CONSTRAINT "Review_productId_fkey" FOREIGN KEY ("productId") REFERENCES "Product"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "Review" ADD CONSTRAINT "Review_userId_fkey" FOREIGN KEY ("userId") REFERENCES "User"("id") ON DELETE CASCADE ON UPDATE CASCADE;
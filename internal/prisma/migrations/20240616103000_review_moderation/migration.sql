@@ -0,0 +1,14 @@
+-- AlterTable
+ALTER TABLE "Review" ADD COLUMN     "status" TEXT NOT NULL DEFAULT 'pending',
+ADD COLUMN     "rejectionReason" TEXT,
+ADD COLUMN     "moderatorId" INTEGER,
+ADD COLUMN     "moderatedAt" TIMESTAMP(3);
+
+-- Reviews written before moderation were already public.
+UPDATE "Review" SET "status" = 'approved';
+
+-- CreateIndex
+CREATE INDEX "Review_status_idx" ON "Review"("status");
+
+-- AddForeignKey
+ALTER TABLE "Review" ADD CONSTRAINT "Review_moderatorId_fkey" FOREIGN KEY ("moderatorId") REFERENCES "User"("id") ON DELETE SET NULL ON UPDATE CASCADE;
@@ -0,0 +1,33 @@
+-- CreateTable
+CREATE TABLE "Coupon" (
+    "id" SERIAL NOT NULL,
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "code" TEXT NOT NULL,
+    "kind" TEXT NOT NULL,
+    "value" INTEGER NOT NULL DEFAULT 0,
+    "minOrder" INTEGER NOT NULL DEFAULT 0,
+    "expiresAt" TIMESTAMP(3),
+    "maxUses" INTEGER,
+    "maxUsesPerUser" INTEGER,
+    "timesUsed" INTEGER NOT NULL DEFAULT 0,
+    "productIds" INTEGER[] DEFAULT ARRAY[]::INTEGER[],
+    "categoryIds" INTEGER[] DEFAULT ARRAY[]::INTEGER[],
+
+    CONSTRAINT "Coupon_pkey" PRIMARY KEY ("id"),
+    CONSTRAINT "Coupon_usage_check" CHECK ("maxUses" IS NULL OR "timesUsed" <= "maxUses")
+);
+
+-- CreateIndex
+CREATE UNIQUE INDEX "Coupon_code_key" ON "Coupon"("code");
+
+-- AlterTable
+ALTER TABLE "Cart" ADD COLUMN     "couponId" INTEGER;
+
+-- AlterTable
+ALTER TABLE "Orders" ADD COLUMN     "couponId" INTEGER;
+
+-- AddForeignKey
+ALTER TABLE "Cart" ADD CONSTRAINT "Cart_couponId_fkey" FOREIGN KEY ("couponId") REFERENCES "Coupon"("id") ON DELETE SET NULL ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "Orders" ADD CONSTRAINT "Orders_couponId_fkey" FOREIGN KEY ("couponId") REFERENCES "Coupon"("id") ON DELETE SET NULL ON UPDATE CASCADE;
@@ -0,0 +1,20 @@
+-- CreateTable
+CREATE TABLE "PaymentAttempt" (
+    "id" SERIAL NOT NULL,
+    "orderId" INTEGER NOT NULL,
+    "gateway" TEXT NOT NULL,
+    "operation" TEXT NOT NULL,
+    "amount" INTEGER NOT NULL,
+    "status" TEXT NOT NULL,
+    "transactionId" TEXT,
+    "error" TEXT,
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT "PaymentAttempt_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "PaymentAttempt_orderId_idx" ON "PaymentAttempt"("orderId");
+
+-- AddForeignKey
+ALTER TABLE "PaymentAttempt" ADD CONSTRAINT "PaymentAttempt_orderId_fkey" FOREIGN KEY ("orderId") REFERENCES "Orders"("id") ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,5 @@
+-- Cancelled and failed orders no longer count as a use of their coupon.
+UPDATE "Coupon" c SET "timesUsed" = (
+    SELECT count(*)::int FROM "Orders" o
+    WHERE o."couponId" = c."id" AND o."status" NOT IN ('cancelled', 'payment_failed')
+);
@@ -0,0 +1,28 @@
+-- CreateTable
+CREATE TABLE "CouponUse" (
+    "couponId" INTEGER NOT NULL,
+    "userId" INTEGER NOT NULL,
+    "uses" INTEGER NOT NULL DEFAULT 0,
+    "maxUses" INTEGER,
+
+    CONSTRAINT "CouponUse_pkey" PRIMARY KEY ("couponId","userId")
+);
+
+-- AddForeignKey
+ALTER TABLE "CouponUse" ADD CONSTRAINT "CouponUse_couponId_fkey" FOREIGN KEY ("couponId") REFERENCES "Coupon"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "CouponUse" ADD CONSTRAINT "CouponUse_userId_fkey" FOREIGN KEY ("userId") REFERENCES "User"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- CopyData
+-- Users already over a coupon's limit keep their count as their limit, so the
+-- check holds for existing rows. The next use writes the real limit back.
+INSERT INTO "CouponUse" ("couponId", "userId", "uses", "maxUses")
+SELECT o."couponId", o."userId", count(*)::int,
+    CASE WHEN c."maxUsesPerUser" IS NULL THEN NULL ELSE GREATEST(c."maxUsesPerUser", count(*)::int) END
+FROM "Orders" o JOIN "Coupon" c ON c."id" = o."couponId"
+WHERE o."status" NOT IN ('cancelled', 'payment_failed')
+GROUP BY o."couponId", o."userId", c."maxUsesPerUser";
+
+-- AddCheckConstraint
+ALTER TABLE "CouponUse" ADD CONSTRAINT "CouponUse_limit_check" CHECK ("maxUses" IS NULL OR "uses" <= "maxUses");
@@ -0,0 +1,11 @@
+-- DropForeignKey
+ALTER TABLE "StockMovement" DROP CONSTRAINT "StockMovement_variantId_fkey";
+
+-- AlterTable
+ALTER TABLE "Product" ADD COLUMN     "archivedAt" TIMESTAMP(3);
+
+-- AlterTable
+ALTER TABLE "ProductVariant" ADD COLUMN     "archivedAt" TIMESTAMP(3);
+
+-- AddForeignKey
+ALTER TABLE "StockMovement" ADD CONSTRAINT "StockMovement_variantId_fkey" FOREIGN KEY ("variantId") REFERENCES "ProductVariant"("id") ON DELETE RESTRICT ON UPDATE CASCADE;
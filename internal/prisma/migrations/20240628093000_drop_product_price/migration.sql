@@ -0,0 +1,3 @@
+-- A product's price is now the lowest price of its variants.
+-- AlterTable
+ALTER TABLE "Product" DROP COLUMN "price";
@@ -0,0 +1,7 @@
+-- Carts may have been topped up past the limit before it was enforced on the
+-- resulting quantity.
+UPDATE "CartItem" SET "quantity" = 100 WHERE "quantity" > 100;
+
+-- AlterCheckConstraint
+ALTER TABLE "CartItem" DROP CONSTRAINT "CartItem_quantity_check";
+ALTER TABLE "CartItem" ADD CONSTRAINT "CartItem_quantity_check" CHECK ("quantity" BETWEEN 1 AND 100);
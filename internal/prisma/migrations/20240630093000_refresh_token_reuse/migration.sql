@@ -0,0 +1,2 @@
+-- AlterTable
+ALTER TABLE "Token" ADD COLUMN     "rotatedAt" TIMESTAMP(3);
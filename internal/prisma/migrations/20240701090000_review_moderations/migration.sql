@@ -0,0 +1,26 @@
+-- CreateTable
+CREATE TABLE "ReviewModeration" (
+    "id" SERIAL NOT NULL,
+    "reviewId" INTEGER NOT NULL,
+    "status" TEXT NOT NULL,
+    "reason" TEXT,
+    "moderatorId" INTEGER,
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT "ReviewModeration_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "ReviewModeration_reviewId_idx" ON "ReviewModeration"("reviewId");
+
+-- AddForeignKey
+ALTER TABLE "ReviewModeration" ADD CONSTRAINT "ReviewModeration_reviewId_fkey" FOREIGN KEY ("reviewId") REFERENCES "Review"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "ReviewModeration" ADD CONSTRAINT "ReviewModeration_moderatorId_fkey" FOREIGN KEY ("moderatorId") REFERENCES "User"("id") ON DELETE SET NULL ON UPDATE CASCADE;
+
+-- Only the latest decision on each review was kept so far.
+INSERT INTO "ReviewModeration" ("reviewId", "status", "reason", "moderatorId", "createdAt")
+SELECT "id", "status", "rejectionReason", "moderatorId", "moderatedAt"
+FROM "Review"
+WHERE "moderatedAt" IS NOT NULL;
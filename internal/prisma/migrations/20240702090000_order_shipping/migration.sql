@@ -0,0 +1,4 @@
+-- Orders placed before shipping was charged keep a shipping charge of zero,
+-- so their totals do not change.
+-- AlterTable
+ALTER TABLE "Orders" ADD COLUMN     "shipping" INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- AlterTable
+ALTER TABLE "Address" ADD COLUMN     "archivedAt" TIMESTAMP(3);
@@ -7,9 +7,13 @@ type Validator struct {
 }
 
 var (
-	EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
-	LinkRX  = regexp.MustCompile(`^(http(s):\/\/.)[-a-zA-Z0-9@:%._\+~#=]{2,256}\.[a-z]{2,6}\b([-a-zA-Z0-9@:%_\+.~#?&//=]*)$`)
-	PhoneRX = regexp.MustCompile(`^\+?[1-9]\d{1,14}$`)
+	EmailRX      = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
+	LinkRX       = regexp.MustCompile(`^(http(s):\/\/.)[-a-zA-Z0-9@:%._\+~#=]{2,256}\.[a-z]{2,6}\b([-a-zA-Z0-9@:%_\+.~#?&//=]*)$`)
+	PhoneRX      = regexp.MustCompile(`^\+?[1-9]\d{1,14}$`)
+	PincodeRX    = regexp.MustCompile(`^[1-9][0-9]{5}$`)
+	SlugRX       = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+	SKURX        = regexp.MustCompile(`^[A-Za-z0-9]+(?:-[A-Za-z0-9]+)*$`)
+	CouponCodeRX = regexp.MustCompile(`^[A-Z0-9]{3,20}$`)
 )
 
 func NewValidator() *Validator {